@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Config.AdminToken == "" {
+			http.Error(w, "admin API is disabled", http.StatusForbidden)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.AdminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (s *Server) handleAdminTaskByID(w http.ResponseWriter, r *http.Request) {
+	ID, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/tasks/"), "/")
+
+	if ID == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case "touch":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.TouchTask(w, r, ID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) TouchTask(w http.ResponseWriter, r *http.Request, ID string) {
+	var body struct {
+		UpdatedAt *time.Time `json:"updated_at"`
+	}
+
+	if err := decodeJSON(r.Body, &body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	at := time.Now()
+	if body.UpdatedAt != nil {
+		at = *body.UpdatedAt
+	}
+
+	task, err := s.DB.TouchTask(ID, at)
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, ErrInvalidTime) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, s.presentTask(r, *task))
+}
+
+// Compactor is implemented by storage backends with an on-disk
+// representation that can be rewritten to reclaim space.
+type Compactor interface {
+	Compact() (reclaimedBytes int64, err error)
+}
+
+type CompactResult struct {
+	Supported      bool  `json:"supported"`
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
+}
+
+func (s *Server) handleAdminCompact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.Compact(w, r)
+}
+
+// Compact is a no-op for in-memory backends such as MapDB.
+func (s *Server) Compact(w http.ResponseWriter, r *http.Request) {
+	compactor, ok := s.DB.(Compactor)
+	if !ok {
+		writeEncoded(w, r, http.StatusOK, CompactResult{})
+		return
+	}
+
+	reclaimed, err := compactor.Compact()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, CompactResult{Supported: true, ReclaimedBytes: reclaimed})
+}
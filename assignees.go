@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+var ErrUnknownAssignee = errors.New("unknown assignee")
+
+// checkAssignee enforces the configured assignee registry. Without one any
+// name is accepted, and leaving a task unassigned is always allowed.
+func (s *Server) checkAssignee(assignee string) error {
+	if len(s.Config.Assignees) == 0 || assignee == "" {
+		return nil
+	}
+	if !slices.Contains(s.Config.Assignees, assignee) {
+		return fmt.Errorf("%w %q", ErrUnknownAssignee, assignee)
+	}
+	return nil
+}
+
+// checkTaskAssignees reports the first task with an unknown assignee as 422.
+// It reports whether the handler may continue.
+func (s *Server) checkTaskAssignees(w http.ResponseWriter, tasks []Task) bool {
+	for i, task := range tasks {
+		if err := s.checkAssignee(task.Assignee); err != nil {
+			http.Error(w, fmt.Sprintf("task %d: %v", i, err), http.StatusUnprocessableEntity)
+			return false
+		}
+	}
+	return true
+}
+
+// updateAssignee returns the assignee set by an update body, if any.
+func updateAssignee(data map[string]interface{}) string {
+	assignee, _ := data["assignee"].(string)
+	return assignee
+}
+
+type AssigneeCount struct {
+	Assignee string `json:"assignee"`
+	Count    int    `json:"count"`
+}
+
+type AssigneesResponse struct {
+	Assignees  []AssigneeCount `json:"assignees"`
+	Unassigned int             `json:"unassigned"`
+}
+
+// countAssignees tallies the distinct assignees in one pass, with tasks
+// that have none counted apart.
+func countAssignees(tasks []Task) AssigneesResponse {
+	counts := make(map[string]int)
+	unassigned := 0
+	for _, task := range tasks {
+		if task.Assignee == "" {
+			unassigned++
+			continue
+		}
+		counts[task.Assignee]++
+	}
+
+	assignees := make([]AssigneeCount, 0, len(counts))
+	for assignee, count := range counts {
+		assignees = append(assignees, AssigneeCount{Assignee: assignee, Count: count})
+	}
+	sort.Slice(assignees, func(i, j int) bool {
+		return assignees[i].Assignee < assignees[j].Assignee
+	})
+
+	return AssigneesResponse{Assignees: assignees, Unassigned: unassigned}
+}
+
+func (s *Server) handleAssignees(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetAssignees(w, r)
+}
+
+// GetAssignees lists who active tasks are assigned to, for filter dropdowns.
+func (s *Server) GetAssignees(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r) {
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(TaskFilter{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, countAssignees(tasks))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// BatchUpdateItem is one entry of POST /tasks/batch-update. Checksum is the
+// task version the client last saw; when set, the item is rejected with 409
+// if the task has changed since.
+type BatchUpdateItem struct {
+	ID       string                 `json:"id"`
+	Checksum string                 `json:"checksum"`
+	Data     map[string]interface{} `json:"data"`
+}
+
+type BatchUpdateOutcome struct {
+	ID   string
+	Task *Task
+	Err  error
+}
+
+type BatchUpdateResult struct {
+	ID     string `json:"id"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Task   *Task  `json:"task,omitempty"`
+}
+
+func batchUpdateStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrArchived), errors.Is(err, ErrStale):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidTime), errors.Is(err, ErrInvalidValue):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNonFinite):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *Server) handleBatchUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.BatchUpdateTasks(w, r)
+}
+
+// BatchUpdateTasks applies many updates in one request. Each item gets its
+// own status. With ?atomic=true nothing is applied unless every item is
+// valid and current, in which case the untouched items report 424.
+func (s *Server) BatchUpdateTasks(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, []string{"atomic"}) {
+		return
+	}
+
+	atomic := false
+	if v := r.URL.Query().Get("atomic"); v != "" {
+		var err error
+		if atomic, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "atomic must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+
+	migrate, err := schemaMigration(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var items []BatchUpdateItem
+	if err := requestCodec(r).Decode(r.Body, &items); err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+	// X-Schema-Version describes the update bodies, not the envelope.
+	if migrate != nil {
+		for _, item := range items {
+			if item.Data != nil {
+				migrate(item.Data)
+			}
+		}
+	}
+
+	if !checkLimit(w, "batch size", s.Config.BatchLimit, len(items), http.StatusRequestEntityTooLarge) {
+		return
+	}
+
+	maxTags := 0
+	for i, item := range items {
+		if err := s.validateUpdate(item.Data); err != nil {
+			http.Error(w, fmt.Sprintf("item %d: %v", i, err), http.StatusUnprocessableEntity)
+			return
+		}
+		maxTags = max(maxTags, updateTagCount(item.Data))
+	}
+	if !checkLimit(w, "tags per task", s.Config.TagLimit, maxTags, http.StatusUnprocessableEntity) {
+		return
+	}
+
+	outcomes, err := s.DB.BatchUpdateTasks(items, atomic)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	results := make([]BatchUpdateResult, len(outcomes))
+	for i, outcome := range outcomes {
+		results[i] = BatchUpdateResult{ID: outcome.ID, Status: batchUpdateStatus(outcome.Err)}
+		if outcome.Err != nil {
+			results[i].Error = outcome.Err.Error()
+			if atomic {
+				status = http.StatusConflict
+			}
+		}
+		if outcome.Task != nil {
+			task := s.presentTask(r, *outcome.Task)
+			results[i].Task = &task
+		}
+	}
+	if status == http.StatusConflict {
+		for i := range results {
+			if results[i].Status == http.StatusOK {
+				results[i].Status = http.StatusFailedDependency
+				results[i].Error = "not applied"
+			}
+		}
+	}
+
+	writeEncoded(w, r, status, results)
+}
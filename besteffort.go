@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// BestEffortResult reports a create that kept whatever parsed before the body
+// broke off. Error is empty when the whole array was read.
+type BestEffortResult struct {
+	Parsed  int    `json:"parsed"`
+	Created []Task `json:"created"`
+	Error   string `json:"error,omitempty"`
+}
+
+// decodeTaskPrefix streams a JSON array of tasks and returns the elements
+// read before the first decode error along with that error. Each element is
+// migrated when migrate is set.
+func decodeTaskPrefix(body io.Reader, migrate func(obj map[string]interface{})) ([]Task, error) {
+	dec := newBodyDecoder(body)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("expected an array of tasks")
+	}
+
+	tasks := []Task{}
+	for dec.More() {
+		var task Task
+		if err := decodeMigrated(dec.Decode, &task, migrate); err != nil {
+			return tasks, fmt.Errorf("element %d: %w", len(tasks), err)
+		}
+		tasks = append(tasks, task)
+	}
+	if _, err := dec.Token(); err != nil {
+		return tasks, fmt.Errorf("after element %d: %w", len(tasks), err)
+	}
+
+	return tasks, nil
+}
+
+// AddTasksBestEffort inserts the well-formed prefix of a truncated array
+// instead of discarding the whole batch. Validation still applies to every
+// parsed task.
+func (s *Server) AddTasksBestEffort(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requestCodec(r).(JSONCodec); !ok {
+		http.Error(w, "best_effort requires a JSON body", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	migrate, err := schemaMigration(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	tasks, decodeErr := decodeTaskPrefix(r.Body, migrate)
+	if decodeErr != nil && len(tasks) == 0 {
+		http.Error(w, fmt.Sprintf("JSON error: %v", decodeErr), http.StatusBadRequest)
+		return
+	}
+
+	for i, task := range tasks {
+		if errs := validateTask(task); len(errs) > 0 {
+			http.Error(w, fmt.Sprintf("task %d: %s", i, strings.Join(errs, "; ")), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !checkLimit(w, "batch size", s.Config.BatchLimit, len(tasks), http.StatusRequestEntityTooLarge) {
+		return
+	}
+	if !checkLimit(w, "tags per task", s.Config.TagLimit, maxTaskTags(tasks), http.StatusUnprocessableEntity) {
+		return
+	}
+
+	if !s.checkTaskAssignees(w, tasks) {
+		return
+	}
+
+	s.applyDefaultPriorities(tasks)
+
+	created, err := s.DB.AddTasks(tasks)
+	if errors.Is(err, ErrIsExist) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	result := BestEffortResult{Parsed: len(tasks), Created: s.presentTasks(r, created)}
+	if decodeErr != nil {
+		result.Error = fmt.Sprintf("JSON error: %v", decodeErr)
+	}
+
+	writeEncoded(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// BulkArchiveRequest must carry the number of tasks the client expects the
+// filter to match, as reported by a prior count such as HEAD /tasks.
+type BulkArchiveRequest struct {
+	Filter  BulkFilter `json:"filter"`
+	Reason  string     `json:"reason"`
+	Confirm *int       `json:"confirm"`
+}
+
+type BulkArchiveMismatch struct {
+	Error   string `json:"error"`
+	Confirm int    `json:"confirm"`
+	Count   int    `json:"count"`
+}
+
+func (s *Server) handleBulkArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.BulkArchiveTasks(w, r)
+}
+
+// BulkArchiveTasks archives every non-archived task matching the filter.
+// A confirm that differs from the actual count archives nothing and is
+// answered with 409 and that count, so a too-broad filter cannot wipe the
+// board by accident.
+func (s *Server) BulkArchiveTasks(w http.ResponseWriter, r *http.Request) {
+	var req BulkArchiveRequest
+
+	if err := decodeJSON(r.Body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.Confirm == nil {
+		http.Error(w, "confirm is required", http.StatusBadRequest)
+		return
+	}
+
+	count, err := s.DB.BulkArchiveTasks(req.Filter.TaskFilter(s.Config.StatusAliases), req.Reason, *req.Confirm)
+
+	if errors.Is(err, ErrUnconfirmed) {
+		writeEncoded(w, r, http.StatusConflict, BulkArchiveMismatch{Error: err.Error(), Confirm: *req.Confirm, Count: count})
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, map[string]int{"archived": count})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// BulkFilter selects the tasks a bulk operation applies to. An empty
+// filter matches every non-archived task.
+type BulkFilter struct {
+	Status   string `json:"status"`
+	Assignee string `json:"assignee"`
+	Tag      string `json:"tag"`
+}
+
+func (f BulkFilter) TaskFilter(aliases map[string]string) TaskFilter {
+	return TaskFilter{Status: f.Status, Assignee: f.Assignee, Tag: f.Tag, StatusAliases: aliases}
+}
+
+type BulkTagRequest struct {
+	Filter BulkFilter `json:"filter"`
+	Add    []string   `json:"add"`
+	Remove []string   `json:"remove"`
+}
+
+func (s *Server) handleBulkTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.BulkTagTasks(w, r)
+}
+
+// BulkTagTasks applies tag additions and removals to every non-archived task
+// matching the filter. An empty filter matches all of them.
+func (s *Server) BulkTagTasks(w http.ResponseWriter, r *http.Request) {
+	var req BulkTagRequest
+
+	if err := decodeJSON(r.Body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		http.Error(w, "add or remove is required", http.StatusBadRequest)
+		return
+	}
+	for _, tag := range req.Add {
+		if strings.TrimSpace(tag) == "" {
+			http.Error(w, "tags must not be empty", http.StatusBadRequest)
+			return
+		}
+	}
+
+	add, _ := dedupeTags(req.Add)
+	count, mostTags, err := s.DB.BulkTagTasks(req.Filter.TaskFilter(s.Config.StatusAliases), add, req.Remove, s.Config.TagLimit.Hard)
+	if errors.Is(err, ErrTooManyTags) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	checkLimit(w, "tags per task", s.Config.TagLimit, mostTags, http.StatusUnprocessableEntity)
+
+	writeEncoded(w, r, http.StatusOK, map[string]int{"tagged": count})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+type Capabilities struct {
+	Storage    string `json:"storage"`
+	Compaction bool   `json:"compaction"`
+
+	ReadOnly    bool `json:"read_only"`
+	Maintenance bool `json:"maintenance"`
+	StrictQuery bool `json:"strict_query"`
+	AdminAPI    bool `json:"admin_api"`
+
+	Notifiers       []string `json:"notifiers"`
+	AsyncOperations bool     `json:"async_operations"`
+
+	// This server has no streaming or response compression; the fields
+	// are reported so clients need not guess.
+	SSE         bool `json:"sse"`
+	WebSocket   bool `json:"websocket"`
+	Compression bool `json:"compression"`
+
+	Codecs         []string `json:"codecs"`
+	PatchPreview   []string `json:"patch_preview_formats"`
+	SchemaVersions []string `json:"schema_versions"`
+	TimeFormats    []string `json:"time_formats"`
+	EmptyFields    []string `json:"empty_fields"`
+	SortFields     []string `json:"sort_fields"`
+	GroupBy        []string `json:"group_by"`
+}
+
+func storageName(db Saver) string {
+	if _, ok := db.(*MapDB); ok {
+		return "memory"
+	}
+	return fmt.Sprintf("%T", db)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// capabilities describes the running server from its wiring and config,
+// so the document cannot drift from what is actually enabled.
+func (s *Server) capabilities() Capabilities {
+	_, compaction := s.DB.(Compactor)
+
+	codecNames := make([]string, len(codecs))
+	for i, codec := range codecs {
+		codecNames[i] = codec.ContentType()
+	}
+
+	notifiers := s.Config.Notifiers
+	if notifiers == nil {
+		notifiers = []string{}
+	}
+
+	window := s.Config.Maintenance
+
+	return Capabilities{
+		Storage:    storageName(s.DB),
+		Compaction: compaction,
+
+		ReadOnly:    s.readOnly.Load(),
+		Maintenance: window != nil && window.Contains(s.clock()),
+		StrictQuery: s.Config.StrictQuery,
+		AdminAPI:    s.Config.AdminToken != "",
+
+		Notifiers:       notifiers,
+		AsyncOperations: s.operations != nil && s.jobs != nil,
+
+		Codecs:         codecNames,
+		PatchPreview:   []string{"application/json-patch+json"},
+		SchemaVersions: append(sortedKeys(schemaMigrations), CurrentSchemaVersion),
+		TimeFormats:    timeFormats,
+		EmptyFields:    emptyFieldsPolicies,
+		SortFields:     sortedKeys(sortableFields),
+		GroupBy:        sortedKeys(groupableFields),
+	}
+}
+
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetCapabilities(w, r)
+}
+
+func (s *Server) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r) {
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, s.capabilities())
+}
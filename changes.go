@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ChangeCreated  = "created"
+	ChangeUpdated  = "updated"
+	ChangeArchived = "archived"
+)
+
+const maxRetainedChanges = 10000
+
+// seqReservation is how many sequence numbers are reserved in the sequence
+// file at a time, so it is rewritten once per block rather than per change.
+const seqReservation = 1000
+
+var ErrChangesExpired = errors.New("changes before this sequence are no longer retained")
+
+type Change struct {
+	Seq  uint64                 `json:"seq"`
+	Type string                 `json:"type"`
+	At   time.Time              `json:"at"`
+	Task Task                   `json:"task"`
+	Diff map[string]FieldChange `json:"diff,omitempty"`
+}
+
+type FieldChange struct {
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+}
+
+// diffTasks compares the canonical encodings of two task states field by
+// field. updated_at is left out since every mutation bumps it.
+func diffTasks(before, after Task) map[string]FieldChange {
+	return diffFields(taskFields(before), taskFields(after))
+}
+
+func taskFields(task Task) map[string]json.RawMessage {
+	var fields map[string]json.RawMessage
+
+	data, _ := canonicalJSON(task)
+	json.Unmarshal(data, &fields)
+
+	return fields
+}
+
+// diffFields reports fields missing on one side as null there, so a nil old
+// map describes a task that did not exist yet.
+func diffFields(oldFields, newFields map[string]json.RawMessage) map[string]FieldChange {
+	diff := make(map[string]FieldChange)
+	for field, newValue := range newFields {
+		if field == "updated_at" {
+			continue
+		}
+		if oldValue, ok := oldFields[field]; !ok || !bytes.Equal(oldValue, newValue) {
+			diff[field] = FieldChange{Old: orNull(oldFields[field]), New: newValue}
+		}
+	}
+	for field, oldValue := range oldFields {
+		if _, ok := newFields[field]; !ok && field != "updated_at" {
+			diff[field] = FieldChange{Old: oldValue, New: orNull(nil)}
+		}
+	}
+
+	return diff
+}
+
+func orNull(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	return value
+}
+
+// changeLog assigns every mutation a global, monotonically increasing
+// sequence number and keeps the most recent ones for incremental sync.
+//
+// With a sequence file the numbering survives restarts: the file holds a
+// mark above every sequence number handed out so far, and a restarted log
+// continues from it. start is where this run began numbering; since_seq
+// values below it come from an earlier run and are reported as expired.
+type changeLog struct {
+	mx          sync.Mutex
+	seq         uint64
+	start       uint64
+	seqFile     string
+	reserved    uint64
+	changes     []Change
+	subscribers []func(Change)
+}
+
+// persistSeq continues the numbering from the mark stored in path, which
+// need not exist yet, and reserves the next block there.
+func (l *changeLog) persistSeq(path string) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var mark uint64
+	if len(data) > 0 {
+		if mark, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return fmt.Errorf("sequence file %s: %w", path, err)
+		}
+	}
+
+	if mark > l.seq {
+		l.seq = mark
+	}
+	l.start = l.seq
+	l.seqFile = path
+	return l.reserveLocked()
+}
+
+// reserveLocked writes a new mark to the sequence file, replacing it
+// atomically so a crash never leaves a lower or partial value behind.
+// l.mx must be held.
+func (l *changeLog) reserveLocked() error {
+	mark := l.seq + 1 + seqReservation
+
+	tmp := l.seqFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(mark, 10)+"\n"), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.seqFile); err != nil {
+		return err
+	}
+	l.reserved = mark
+	return nil
+}
+
+func (l *changeLog) record(changeType string, task Task) {
+	l.append(Change{Type: changeType, Task: task})
+}
+
+func (l *changeLog) recordDiff(changeType string, before, after Task) {
+	l.append(Change{Type: changeType, Task: after, Diff: diffTasks(before, after)})
+}
+
+func (l *changeLog) append(change Change) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	// The next number must stay below the stored mark, or a restart could
+	// hand it out again.
+	if l.seqFile != "" && l.seq+1 >= l.reserved {
+		if err := l.reserveLocked(); err != nil {
+			log.Printf("change log: reserving sequence numbers: %v\n", err)
+		}
+	}
+
+	l.seq++
+	change.Seq = l.seq
+	change.At = time.Now()
+	l.changes = append(l.changes, change)
+	if len(l.changes) > maxRetainedChanges {
+		l.changes = l.changes[len(l.changes)-maxRetainedChanges:]
+	}
+
+	for _, fn := range l.subscribers {
+		fn(change)
+	}
+}
+
+// since returns up to limit changes after seq, or all of them when limit is
+// zero, along with the latest sequence number. seq 0 reads from the start of
+// this run.
+func (l *changeLog) since(seq uint64, limit int) ([]Change, uint64, error) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if seq == 0 {
+		seq = l.start
+	}
+	if seq < l.start || (len(l.changes) > 0 && seq+1 < l.changes[0].Seq) {
+		return nil, l.seq, ErrChangesExpired
+	}
+
+	changes := []Change{}
+	for _, change := range l.changes {
+		if limit > 0 && len(changes) == limit {
+			break
+		}
+		if change.Seq > seq {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, l.seq, nil
+}
+
+// history returns the retained changes of one task, oldest first. complete
+// is false when older entries may have been trimmed from the log.
+func (l *changeLog) history(ID string) ([]Change, bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	var changes []Change
+	for _, change := range l.changes {
+		if change.Task.ID == ID {
+			changes = append(changes, change)
+		}
+	}
+
+	complete := len(l.changes) == 0 || l.changes[0].Seq == l.start+1 ||
+		(len(changes) > 0 && changes[0].Type == ChangeCreated)
+	return changes, complete
+}
+
+// PersistChangeSeq keeps the change sequence in path, so clients syncing
+// with since_seq never see a number reused after a restart.
+func (db *MapDB) PersistChangeSeq(path string) error {
+	return db.changes.persistSeq(path)
+}
+
+func (db *MapDB) GetTaskHistory(ID string) ([]Change, bool, error) {
+	changes, complete := db.changes.history(ID)
+	return changes, complete, nil
+}
+
+func (db *MapDB) GetChanges(sinceSeq uint64, limit int) ([]Change, uint64, error) {
+	return db.changes.since(sinceSeq, limit)
+}
+
+// ChangesResponse is one page of the feed. NextSeq is the since_seq for the
+// following page; the backlog is drained once it reaches LastSeq.
+type ChangesResponse struct {
+	Changes []Change `json:"changes"`
+	LastSeq uint64   `json:"last_seq"`
+	NextSeq uint64   `json:"next_seq"`
+	HasMore bool     `json:"has_more"`
+}
+
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetChanges(w, r)
+}
+
+func (s *Server) GetChanges(w http.ResponseWriter, r *http.Request) {
+	var sinceSeq uint64
+
+	if v := r.URL.Query().Get("since_seq"); v != "" {
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "since_seq must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		sinceSeq = parsed
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	changes, lastSeq, err := s.DB.GetChanges(sinceSeq, limit)
+	if errors.Is(err, ErrChangesExpired) {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range changes {
+		changes[i].Task = s.presentTask(r, changes[i].Task)
+	}
+
+	nextSeq := sinceSeq
+	if len(changes) > 0 {
+		nextSeq = changes[len(changes)-1].Seq
+	}
+
+	writeEncoded(w, r, http.StatusOK, ChangesResponse{Changes: changes, LastSeq: lastSeq, NextSeq: nextSeq, HasMore: nextSeq < lastSeq})
+}
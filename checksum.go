@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// canonicalJSON is the stable encoding of a stored task: timestamps in UTC
+// and response-only fields cleared, so the same state always hashes the same.
+func canonicalJSON(task Task) ([]byte, error) {
+	task.Checksum = ""
+	task.timeFormat = ""
+	task.emptyFields = ""
+	task.RemainingMinutes = 0
+	task.LeadTimeSeconds = nil
+	task.CycleTimeSeconds = nil
+	task.CreatedAt = task.CreatedAt.UTC()
+	task.UpdatedAt = task.UpdatedAt.UTC()
+	if task.StartedAt != nil {
+		startedAt := task.StartedAt.UTC()
+		task.StartedAt = &startedAt
+	}
+	if task.CompletedAt != nil {
+		completedAt := task.CompletedAt.UTC()
+		task.CompletedAt = &completedAt
+	}
+	if task.ArchivedAt != nil {
+		archivedAt := task.ArchivedAt.UTC()
+		task.ArchivedAt = &archivedAt
+	}
+	if task.DueAt != nil {
+		dueAt := task.DueAt.UTC()
+		task.DueAt = &dueAt
+	}
+
+	return json.Marshal(task)
+}
+
+func taskChecksum(task Task) string {
+	data, err := canonicalJSON(task)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) handleChecksums(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetChecksums(w, r)
+}
+
+func (s *Server) GetChecksums(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, filterParams) {
+		return
+	}
+
+	filter, err := s.parseTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	checksums := make(map[string]string, len(tasks))
+	for _, task := range tasks {
+		checksums[task.ID] = taskChecksum(task)
+	}
+
+	writeEncoded(w, r, http.StatusOK, checksums)
+}
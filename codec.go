@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type Codec interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return "application/json" }
+
+func (JSONCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (JSONCodec) Decode(r io.Reader, v interface{}) error {
+	return decodeJSON(r, v)
+}
+
+// MsgpackCodec reuses the json struct tags so Task has a single field naming.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (MsgpackCodec) Encode(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func (MsgpackCodec) Decode(r io.Reader, v interface{}) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+var codecs = []Codec{JSONCodec{}, MsgpackCodec{}}
+
+func codecFor(mediaType string) Codec {
+	for _, codec := range codecs {
+		if codec.ContentType() == mediaType {
+			return codec
+		}
+	}
+	if mediaType == "application/x-msgpack" {
+		return MsgpackCodec{}
+	}
+	return nil
+}
+
+// requestCodec picks the decoder from Content-Type, falling back to JSON.
+func requestCodec(r *http.Request) Codec {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil {
+		if codec := codecFor(mediaType); codec != nil {
+			return codec
+		}
+	}
+	return JSONCodec{}
+}
+
+// responseCodec picks the first supported type listed in Accept, falling back to JSON.
+func responseCodec(r *http.Request) Codec {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		if codec := codecFor(mediaType); codec != nil {
+			return codec
+		}
+	}
+	return JSONCodec{}
+}
+
+// writeEncoded encodes v with the negotiated codec, trimmed to ?fields= when
+// the client asked for a selection.
+func writeEncoded(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	codec, v, ok := prepareResponse(w, r, v)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", codec.ContentType())
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	codec.Encode(w, v)
+}
+
+// prepareResponse applies ?fields= to v and picks the response codec. On a
+// bad selection it answers the request itself and reports false.
+func prepareResponse(w http.ResponseWriter, r *http.Request, v interface{}) (Codec, interface{}, bool) {
+	if selection := r.URL.Query().Get("fields"); selection != "" {
+		tree, err := parseFields(selection)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, nil, false
+		}
+		if v, err = project(v, tree); err != nil {
+			http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusInternalServerError)
+			return nil, nil, false
+		}
+	}
+
+	w.Header().Add("Vary", "Accept")
+	return responseCodec(r), v, true
+}
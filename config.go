@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type Config struct {
+	Addr        string `json:"addr"`
+	AdminToken  string `json:"admin_token"`
+	SortTags    bool   `json:"sort_tags"`
+	MaxOffset   int    `json:"max_offset"`
+	Timezone    string `json:"timezone"`
+	DefaultSort string `json:"default_sort"`
+	TimeFormat  string `json:"time_format"`
+	StrictQuery bool   `json:"strict_query"`
+	ReadOnly    bool   `json:"read_only"`
+
+	DeleteMissingOK bool `json:"delete_missing_ok"`
+
+	// EmptyFields is how unset optional task fields are written:
+	// "default" keeps each field's own rule, "omitempty" leaves them out,
+	// "explicit-null" writes null and "always-present" writes zero values.
+	EmptyFields string `json:"empty_fields"`
+
+	// RetryAfterFormat is "seconds" or "http-date"; clients can pick either
+	// per request with Prefer: retry-after=<format>.
+	RetryAfterFormat string `json:"retry_after_format"`
+
+	// MaxResponseBytes, when positive, caps the encoded size of list
+	// responses; longer lists are cut and continue via X-Next-Cursor.
+	MaxResponseBytes int `json:"max_response_bytes"`
+
+	// MaxUpdateBytes caps the body of PUT /tasks/{id}, answered with 413
+	// when exceeded; MaxUpdateDepth caps how deeply its values may nest.
+	MaxUpdateBytes int64 `json:"max_update_bytes"`
+	MaxUpdateDepth int   `json:"max_update_depth"`
+
+	Maintenance *MaintenanceWindow `json:"maintenance"`
+
+	// DuplicateTags decides what an update with repeated tags does:
+	// "dedupe" drops the repeats, "reject" fails with 422.
+	DuplicateTags string `json:"duplicate_tags"`
+
+	// DefaultPriorities maps the status a task is created in to the
+	// priority it gets when none is given.
+	DefaultPriorities map[string]int `json:"default_priorities"`
+
+	// TagLimit bounds the tags per task and BatchLimit the tasks per create,
+	// import or batch-update request. Above the soft limit the request succeeds
+	// with a Warning header; above the hard one it is rejected.
+	TagLimit   SoftLimit `json:"tag_limit"`
+	BatchLimit SoftLimit `json:"batch_limit"`
+
+	// StatusAliases renames legacy stored statuses on read, e.g.
+	// {"open": "created"}. Stored data is left as it is.
+	StatusAliases map[string]string `json:"status_aliases"`
+
+	// Assignees, when set, is the registry of people tasks may be
+	// assigned to.
+	Assignees []string `json:"assignees"`
+
+	MaxConcurrentImports int `json:"max_concurrent_imports"`
+
+	ReadyLockWaitThresholdMS int `json:"ready_lock_wait_threshold_ms"`
+	StartupDelayMS           int `json:"startup_delay_ms"`
+	ShutdownTimeoutMS        int `json:"shutdown_timeout_ms"`
+
+	// MaxTaskVersions bounds how many past states of a task
+	// GET /tasks/{id}?at= and /tasks/{id}/diff read back from the change log.
+	MaxTaskVersions int `json:"max_task_versions"`
+
+	// ChangeSeqFile, when set, is where the /tasks/changes sequence is kept
+	// across restarts.
+	ChangeSeqFile string `json:"change_seq_file"`
+
+	// OperationTTLSeconds is how long a finished async operation stays
+	// pollable at /operations/{id}.
+	OperationTTLSeconds int `json:"operation_ttl_seconds"`
+
+	Notifiers    []string `json:"notifiers"`
+	NotifyBuffer int      `json:"notify_buffer"`
+
+	// NotifyBatchSize bounds how many notifiers are delivered to at once;
+	// one that takes longer than NotifyTimeoutMS is dropped.
+	NotifyBatchSize int `json:"notify_batch_size"`
+	NotifyTimeoutMS int `json:"notify_timeout_ms"`
+
+	// Debug enables development-only behaviour such as ForcedErrors and
+	// must stay off in production.
+	Debug        bool           `json:"debug"`
+	ForcedErrors map[string]int `json:"forced_errors"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Addr:        "localhost:8080",
+		SortTags:    true,
+		MaxOffset:   10000,
+		Timezone:    "UTC",
+		DefaultSort: "created_at desc",
+		TimeFormat:  TimeFormatRFC3339,
+
+		RetryAfterFormat: RetryAfterSeconds,
+		EmptyFields:      EmptyFieldsDefault,
+
+		MaxUpdateBytes: 16 << 10,
+		MaxUpdateDepth: 1,
+
+		MaxConcurrentImports: 1,
+
+		DuplicateTags: DuplicateTagsDedupe,
+
+		ReadyLockWaitThresholdMS: 100,
+		ShutdownTimeoutMS:        10000,
+
+		MaxTaskVersions: 100,
+
+		OperationTTLSeconds: 3600,
+
+		NotifyBuffer:    256,
+		NotifyBatchSize: 8,
+		NotifyTimeoutMS: 5000,
+	}
+}
+
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+
+	if _, err := loadLocation(cfg.Timezone); err != nil {
+		return cfg, fmt.Errorf("config timezone: %w", err)
+	}
+
+	if _, err := parseSort(cfg.DefaultSort); err != nil {
+		return cfg, fmt.Errorf("config default_sort: %w", err)
+	}
+
+	if !validTimeFormat(cfg.TimeFormat) {
+		return cfg, fmt.Errorf("config time_format: unknown format %q", cfg.TimeFormat)
+	}
+
+	if !validEmptyFields(cfg.EmptyFields) {
+		return cfg, fmt.Errorf("config empty_fields: unknown policy %q", cfg.EmptyFields)
+	}
+
+	if err := cfg.TagLimit.validate(); err != nil {
+		return cfg, fmt.Errorf("config tag_limit: %w", err)
+	}
+
+	if err := cfg.BatchLimit.validate(); err != nil {
+		return cfg, fmt.Errorf("config batch_limit: %w", err)
+	}
+
+	if !validRetryAfterFormat(cfg.RetryAfterFormat) {
+		return cfg, fmt.Errorf("config retry_after_format: unknown format %q", cfg.RetryAfterFormat)
+	}
+
+	if cfg.MaxUpdateBytes < 1 {
+		return cfg, fmt.Errorf("config max_update_bytes: must be at least 1")
+	}
+
+	if cfg.MaxUpdateDepth < 1 {
+		return cfg, fmt.Errorf("config max_update_depth: must be at least 1")
+	}
+
+	if cfg.MaxConcurrentImports < 1 {
+		return cfg, fmt.Errorf("config max_concurrent_imports: must be at least 1")
+	}
+
+	if cfg.DuplicateTags != DuplicateTagsDedupe && cfg.DuplicateTags != DuplicateTagsReject {
+		return cfg, fmt.Errorf("config duplicate_tags: unknown policy %q", cfg.DuplicateTags)
+	}
+
+	if cfg.Maintenance != nil && !cfg.Maintenance.End.After(cfg.Maintenance.Start) {
+		return cfg, fmt.Errorf("config maintenance: end must be after start")
+	}
+
+	if cfg.OperationTTLSeconds < 1 {
+		return cfg, fmt.Errorf("config operation_ttl_seconds: must be at least 1")
+	}
+
+	if cfg.MaxTaskVersions < 1 {
+		return cfg, fmt.Errorf("config max_task_versions: must be at least 1")
+	}
+
+	if cfg.ShutdownTimeoutMS < 1 {
+		return cfg, fmt.Errorf("config shutdown_timeout_ms: must be at least 1")
+	}
+
+	if cfg.StartupDelayMS < 0 {
+		return cfg, fmt.Errorf("config startup_delay_ms: must not be negative")
+	}
+
+	if cfg.NotifyBatchSize < 1 {
+		return cfg, fmt.Errorf("config notify_batch_size: must be at least 1")
+	}
+	if cfg.NotifyTimeoutMS < 1 {
+		return cfg, fmt.Errorf("config notify_timeout_ms: must be at least 1")
+	}
+
+	for _, name := range cfg.Notifiers {
+		if _, err := notifierByName(name); err != nil {
+			return cfg, fmt.Errorf("config notifiers: %w", err)
+		}
+	}
+
+	for status, priority := range cfg.DefaultPriorities {
+		if priority < 0 {
+			return cfg, fmt.Errorf("config default_priorities: %q: priority must not be negative", status)
+		}
+	}
+
+	for legacy, canonical := range cfg.StatusAliases {
+		if canonical == "" {
+			return cfg, fmt.Errorf("config status_aliases: %q: canonical status must not be empty", legacy)
+		}
+		if _, ok := cfg.StatusAliases[canonical]; ok {
+			return cfg, fmt.Errorf("config status_aliases: %q: %q is itself an alias", legacy, canonical)
+		}
+	}
+
+	for ID, code := range cfg.ForcedErrors {
+		if code < 400 || code > 599 {
+			return cfg, fmt.Errorf("config forced_errors: %q: status %d is not an error status", ID, code)
+		}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// newBodyDecoder returns a JSON decoder for a request body with a leading
+// UTF-8 byte order mark skipped.
+func newBodyDecoder(body io.Reader) *json.Decoder {
+	br := bufio.NewReader(body)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	return json.NewDecoder(br)
+}
+
+// decodeJSON decodes exactly one JSON value from body. Trailing whitespace is
+// allowed, anything else after the value is an error.
+func decodeJSON(body io.Reader, v interface{}) error {
+	dec := newBodyDecoder(body)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after JSON value")
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const (
+	EmptyFieldsDefault       = "default"
+	EmptyFieldsOmit          = "omitempty"
+	EmptyFieldsExplicitNull  = "explicit-null"
+	EmptyFieldsAlwaysPresent = "always-present"
+)
+
+// emptyFieldsPolicies lists every supported empty_fields policy, in the
+// order capabilities reports them.
+var emptyFieldsPolicies = []string{EmptyFieldsDefault, EmptyFieldsOmit, EmptyFieldsExplicitNull, EmptyFieldsAlwaysPresent}
+
+func validEmptyFields(policy string) bool {
+	return slices.Contains(emptyFieldsPolicies, policy)
+}
+
+// requestEmptyFields honours an empty-fields parameter on an
+// application/json Accept entry, e.g. "application/json;
+// empty-fields=explicit-null", and otherwise falls back to the config.
+func (s *Server) requestEmptyFields(r *http.Request) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || mediaType != "application/json" {
+			continue
+		}
+		if policy := params["empty-fields"]; validEmptyFields(policy) {
+			return policy
+		}
+	}
+	return s.Config.EmptyFields
+}
+
+// optionalTaskFields maps each optional task field to its zero value for
+// the always-present policy.
+var optionalTaskFields = map[string]json.RawMessage{
+	"started_at":      json.RawMessage(`null`),
+	"completed_at":    json.RawMessage(`null`),
+	"archived_at":     json.RawMessage(`null`),
+	"archive_reason":  json.RawMessage(`""`),
+	"merged_into":     json.RawMessage(`""`),
+	"external_id":     json.RawMessage(`""`),
+	"tags":            json.RawMessage(`[]`),
+	"assignee":        json.RawMessage(`""`),
+	"due_at":          json.RawMessage(`null`),
+	"depends_on":      json.RawMessage(`[]`),
+	"locked_by":       json.RawMessage(`""`),
+	"locked_at":       json.RawMessage(`null`),
+	"lock_expires_at": json.RawMessage(`null`),
+}
+
+// taskFieldOrder is the declaration order of the task's JSON fields, so
+// fields the policy adds back land where the struct would put them.
+var taskFieldOrder = func() []string {
+	var names []string
+	taskType := reflect.TypeOf(Task{})
+	for i := 0; i < taskType.NumField(); i++ {
+		name, _, _ := strings.Cut(taskType.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}()
+
+func isEmptyJSON(value json.RawMessage) bool {
+	switch string(bytes.TrimSpace(value)) {
+	case "null", `""`, "[]", "{}":
+		return true
+	}
+	return false
+}
+
+// applyEmptyFields rewrites the unset optional fields of an encoded task
+// according to policy. Other fields are copied as they are.
+func applyEmptyFields(data []byte, policy string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for name, zero := range optionalTaskFields {
+		value, ok := fields[name]
+		if ok && !isEmptyJSON(value) {
+			continue
+		}
+
+		switch policy {
+		case EmptyFieldsOmit:
+			delete(fields, name)
+		case EmptyFieldsExplicitNull:
+			fields[name] = json.RawMessage(`null`)
+		case EmptyFieldsAlwaysPresent:
+			fields[name] = zero
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	write := func(name string, value json.RawMessage) {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		fmt.Fprintf(&buf, "%q:", name)
+		buf.Write(value)
+	}
+	for _, name := range taskFieldOrder {
+		if value, ok := fields[name]; ok {
+			write(name, value)
+			delete(fields, name)
+		}
+	}
+	for name, value := range fields {
+		write(name, value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+// parseMinutes reads a non-negative whole number of minutes from a decoded
+// update body. JSON numbers arrive as float64, msgpack ones as sized ints.
+func parseMinutes(data map[string]interface{}, key string) (int, bool, error) {
+	value, ok := data[key]
+	if !ok {
+		return 0, false, nil
+	}
+
+	var minutes int
+	switch v := value.(type) {
+	case float64:
+		n, err := wholeNumber(key, v)
+		if err != nil {
+			return 0, false, err
+		}
+		minutes = n
+	case float32:
+		n, err := wholeNumber(key, float64(v))
+		if err != nil {
+			return 0, false, err
+		}
+		minutes = n
+	case int8:
+		minutes = int(v)
+	case int16:
+		minutes = int(v)
+	case int32:
+		minutes = int(v)
+	case int64:
+		minutes = int(v)
+	case uint8:
+		minutes = int(v)
+	case uint16:
+		minutes = int(v)
+	case uint32:
+		minutes = int(v)
+	case uint64:
+		minutes = int(v)
+	default:
+		return 0, false, fmt.Errorf("%w: %s must be a number", ErrInvalidValue, key)
+	}
+
+	if minutes < 0 {
+		return 0, false, fmt.Errorf("%w: %s must not be negative", ErrInvalidValue, key)
+	}
+
+	return minutes, true, nil
+}
+
+// wholeNumber converts a decoded float to an int. NaN and infinities cannot
+// come from JSON but can from msgpack, and are rejected separately from
+// merely fractional or out-of-range values.
+func wholeNumber(key string, v float64) (int, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%w: %s", ErrNonFinite, key)
+	}
+	if v != math.Trunc(v) || v < math.MinInt32 || v > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %s must be a whole number", ErrInvalidValue, key)
+	}
+	return int(v), nil
+}
+
+func (s *Server) AddSpentMinutes(w http.ResponseWriter, r *http.Request, ID string) {
+	var body struct {
+		Minutes int `json:"minutes"`
+	}
+
+	if err := requestCodec(r).Decode(r.Body, &body); err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if body.Minutes <= 0 {
+		http.Error(w, "minutes must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	task, err := s.DB.AddSpentMinutes(ID, body.Minutes)
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, ErrArchived) {
+		http.Error(w, ErrArchived.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeWritten(w, r, http.StatusOK, taskLocation(task.ID), s.presentTask(r, *task))
+}
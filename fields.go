@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FieldTree is a parsed ?fields= selection. A nil subtree selects the
+// whole value, e.g. fields=id,task(id,title) keeps id and two task fields.
+type FieldTree map[string]FieldTree
+
+// parseFields parses a comma-separated field list in which any name may be
+// followed by a parenthesised selection of its own fields.
+func parseFields(selection string) (FieldTree, error) {
+	tree, rest, err := parseFieldList(selection)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("fields: unexpected %q", rest)
+	}
+	return tree, nil
+}
+
+func parseFieldList(s string) (FieldTree, string, error) {
+	tree := make(FieldTree)
+	for {
+		end := strings.IndexAny(s, ",()")
+		if end < 0 {
+			end = len(s)
+		}
+
+		name := strings.TrimSpace(s[:end])
+		if !validFieldName(name) {
+			return nil, "", fmt.Errorf("fields: invalid field name %q", name)
+		}
+		if _, ok := tree[name]; ok {
+			return nil, "", fmt.Errorf("fields: %q is selected twice", name)
+		}
+		s = s[end:]
+
+		var sub FieldTree
+		if strings.HasPrefix(s, "(") {
+			var err error
+			sub, s, err = parseFieldList(s[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			if !strings.HasPrefix(s, ")") {
+				return nil, "", fmt.Errorf("fields: missing ')' after %q", name)
+			}
+			s = s[1:]
+		}
+		tree[name] = sub
+
+		if !strings.HasPrefix(s, ",") {
+			return tree, s, nil
+		}
+		s = s[1:]
+	}
+}
+
+// withFields rejects a malformed ?fields= before the handler runs, so a
+// write is not applied only for its response to fail.
+func withFields(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if selection := r.URL.Query().Get("fields"); selection != "" {
+			if _, err := parseFields(selection); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// project encodes v as JSON, so custom marshalers such as the task time
+// formats still apply, and keeps only the selected fields. Arrays are
+// projected element by element.
+func project(v interface{}, tree FieldTree) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return projectValue(decoded, tree), nil
+}
+
+func projectValue(v interface{}, tree FieldTree) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = projectValue(elem, tree)
+		}
+		return v
+	case map[string]interface{}:
+		if tree == nil {
+			return numbersToValues(v)
+		}
+		projected := make(map[string]interface{}, len(tree))
+		for name, sub := range tree {
+			if value, ok := v[name]; ok {
+				projected[name] = projectValue(value, sub)
+			}
+		}
+		return projected
+	}
+	return numbersToValues(v)
+}
+
+// numbersToValues turns json.Number back into int64 or float64 so every
+// codec, not just JSON, encodes them as numbers.
+func numbersToValues(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = numbersToValues(elem)
+		}
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = numbersToValues(elem)
+		}
+	}
+	return v
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// markTransition records the status transition timestamps: StartedAt the
+// first time a task goes in_progress, CompletedAt when it reaches "done".
+// Leaving "done" clears CompletedAt again.
+func markTransition(task *Task, now time.Time) {
+	if task.Status == "in_progress" && task.StartedAt == nil {
+		task.StartedAt = &now
+	}
+
+	if task.Status != "done" {
+		task.CompletedAt = nil
+		return
+	}
+	if task.CompletedAt == nil {
+		task.CompletedAt = &now
+	}
+}
+
+// startStatus puts a new task in the status its body named, InitialStatus
+// when it named none, and records the matching transition timestamps.
+func startStatus(task *Task, now time.Time) {
+	if task.Status == "" {
+		task.Status = InitialStatus
+	}
+	markTransition(task, now)
+}
+
+// flowTimes returns the lead time (created to done) and cycle time
+// (in_progress to done) in seconds. Both are nil until the task is done;
+// cycle time also stays nil for a task that was never in progress.
+func flowTimes(task Task) (lead, cycle *int64) {
+	if task.CompletedAt == nil {
+		return nil, nil
+	}
+
+	leadSeconds := int64(task.CompletedAt.Sub(task.CreatedAt) / time.Second)
+	lead = &leadSeconds
+	if task.StartedAt != nil {
+		cycleSeconds := int64(task.CompletedAt.Sub(*task.StartedAt) / time.Second)
+		cycle = &cycleSeconds
+	}
+	return lead, cycle
+}
+
+type FlowStats struct {
+	Count int     `json:"count"`
+	Avg   float64 `json:"avg_seconds"`
+	P50   int64   `json:"p50_seconds"`
+	P85   int64   `json:"p85_seconds"`
+	P95   int64   `json:"p95_seconds"`
+}
+
+type FlowMetrics struct {
+	LeadTime  FlowStats `json:"lead_time"`
+	CycleTime FlowStats `json:"cycle_time"`
+}
+
+// percentile uses the nearest-rank method on sorted values.
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+func flowStats(values []int64) FlowStats {
+	if len(values) == 0 {
+		return FlowStats{}
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+	return FlowStats{
+		Count: len(values),
+		Avg:   float64(sum) / float64(len(values)),
+		P50:   percentile(values, 50),
+		P85:   percentile(values, 85),
+		P95:   percentile(values, 95),
+	}
+}
+
+func computeFlowMetrics(tasks []Task) FlowMetrics {
+	var leads, cycles []int64
+	for _, task := range tasks {
+		lead, cycle := flowTimes(task)
+		if lead != nil {
+			leads = append(leads, *lead)
+		}
+		if cycle != nil {
+			cycles = append(cycles, *cycle)
+		}
+	}
+	return FlowMetrics{LeadTime: flowStats(leads), CycleTime: flowStats(cycles)}
+}
+
+func (s *Server) handleFlowMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetFlowMetrics(w, r)
+}
+
+// GetFlowMetrics aggregates lead and cycle times over the done tasks that
+// match the usual filters. Done tasks archived since count with
+// ?include_archived=true.
+func (s *Server) GetFlowMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, filterParams) {
+		return
+	}
+
+	filter, err := s.parseTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, computeFlowMetrics(tasks))
+}
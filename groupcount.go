@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// groupableFields maps a group-by name to the values a task falls under.
+// Multi-valued fields such as tags count a task once per value.
+var groupableFields = map[string]func(task Task) []string{
+	"status":   func(task Task) []string { return []string{task.Status} },
+	"assignee": func(task Task) []string { return []string{task.Assignee} },
+	"priority": func(task Task) []string { return []string{strconv.Itoa(task.Priority)} },
+	"tag":      func(task Task) []string { return task.Tags },
+}
+
+func groupCount(tasks []Task, groupBy func(task Task) []string) map[string]int {
+	counts := make(map[string]int)
+	for _, task := range tasks {
+		for _, value := range groupBy(task) {
+			counts[value]++
+		}
+	}
+	return counts
+}
+
+func (s *Server) handleGroupCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetGroupCount(w, r)
+}
+
+func (s *Server) GetGroupCount(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, filterParams, []string{"by"}) {
+		return
+	}
+
+	by := r.URL.Query().Get("by")
+	groupBy, ok := groupableFields[by]
+	if !ok {
+		fields := make([]string, 0, len(groupableFields))
+		for field := range groupableFields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		http.Error(w, fmt.Sprintf("cannot group by %q, use one of: %s", by, strings.Join(fields, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := s.parseTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.canonicalStatuses(tasks)
+
+	writeEncoded(w, r, http.StatusOK, groupCount(tasks, groupBy))
+}
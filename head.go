@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type TaskCounts struct {
+	Total        int
+	Created      int
+	Archived     int
+	LastModified time.Time
+}
+
+// countTasks aggregates tasks in one pass, counting those in status initial
+// as created. Archiving does not bump UpdatedAt, so ArchivedAt counts
+// towards LastModified as well.
+func countTasks(tasks []Task, initial string) TaskCounts {
+	var counts TaskCounts
+
+	for _, task := range tasks {
+		counts.Total++
+		if task.Status == initial {
+			counts.Created++
+		}
+		if task.UpdatedAt.After(counts.LastModified) {
+			counts.LastModified = task.UpdatedAt
+		}
+		if task.ArchivedAt != nil {
+			counts.Archived++
+			if task.ArchivedAt.After(counts.LastModified) {
+				counts.LastModified = *task.ArchivedAt
+			}
+		}
+	}
+
+	return counts
+}
+
+// HeadTasks reports aggregate counts in headers only, so monitoring can poll
+// without downloading the list. Archived tasks are always counted.
+func (s *Server) HeadTasks(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, filterParams) {
+		return
+	}
+
+	filter, err := s.parseTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.IncludeArchived = true
+
+	tasks, err := s.DB.GetTasks(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.canonicalStatuses(tasks)
+	counts := countTasks(tasks, s.canonicalStatus(InitialStatus))
+	w.Header().Set("X-Total-Count", strconv.Itoa(counts.Total))
+	w.Header().Set("X-Count-Created", strconv.Itoa(counts.Created))
+	w.Header().Set("X-Count-Archived", strconv.Itoa(counts.Archived))
+	if !counts.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", counts.LastModified.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+}
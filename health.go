@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+type LockWaitReporter interface {
+	LockWaitAverage() time.Duration
+}
+
+type ReadinessStatus struct {
+	Status          string  `json:"status"`
+	LockWaitAvgMS   float64 `json:"lock_wait_avg_ms,omitempty"`
+	LockWaitLimitMS int     `json:"lock_wait_limit_ms,omitempty"`
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.Readyz(w, r)
+}
+
+func (s *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	if s.warmingUp.Load() {
+		writeEncoded(w, r, http.StatusServiceUnavailable, ReadinessStatus{Status: "starting"})
+		return
+	}
+
+	status := ReadinessStatus{Status: "ready"}
+	code := http.StatusOK
+
+	reporter, ok := s.DB.(LockWaitReporter)
+	if ok && s.Config.ReadyLockWaitThresholdMS > 0 {
+		avg := reporter.LockWaitAverage()
+		status.LockWaitAvgMS = float64(avg) / float64(time.Millisecond)
+		status.LockWaitLimitMS = s.Config.ReadyLockWaitThresholdMS
+
+		if avg > time.Duration(s.Config.ReadyLockWaitThresholdMS)*time.Millisecond {
+			status.Status = "degraded"
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	writeEncoded(w, r, code, status)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type TaskDiff struct {
+	ID   string                 `json:"id"`
+	From time.Time              `json:"from"`
+	To   time.Time              `json:"to"`
+	Diff map[string]FieldChange `json:"diff"`
+
+	// FromKnown is false when nothing is recorded for the task at From,
+	// either because it did not exist yet or because that part of the log
+	// was trimmed. Old values are then null.
+	FromKnown bool `json:"from_known"`
+}
+
+// stateAt returns the task as of the last change at or before at.
+func stateAt(history []Change, at time.Time) (Task, bool) {
+	var state Task
+	found := false
+
+	for _, change := range history {
+		if change.At.After(at) {
+			break
+		}
+		state = change.Task
+		found = true
+	}
+
+	return state, found
+}
+
+func parseDiffTime(r *http.Request, key string, fallback time.Time) (time.Time, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return at, fmt.Errorf("%s must be an RFC 3339 time", key)
+	}
+	return at, nil
+}
+
+// taskHistory is the change history of one task as /diff and ?at= both
+// read it: the newest MaxTaskVersions changes of the retained log. complete
+// is false when older changes are missing.
+func (s *Server) taskHistory(ID string) ([]Change, bool, error) {
+	history, complete, err := s.DB.GetTaskHistory(ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if max := s.Config.MaxTaskVersions; len(history) > max {
+		history = history[len(history)-max:]
+		complete = false
+	}
+	return history, complete, nil
+}
+
+// GetTaskAt serves GET /tasks/{id}?at=<RFC3339>. A time before the task
+// existed, or before its oldest retained change, is a 404.
+func (s *Server) GetTaskAt(w http.ResponseWriter, r *http.Request, ID string) {
+	at, err := time.Parse(time.RFC3339Nano, r.URL.Query().Get("at"))
+	if err != nil {
+		http.Error(w, "at must be an RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+
+	history, _, err := s.taskHistory(ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	task, ok := stateAt(history, at)
+	if !ok {
+		http.Error(w, "no recorded version of this task at that time", http.StatusNotFound)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, s.presentTask(r, task))
+}
+
+// GetTaskDiff reconstructs the task at two points in time from the change
+// log and reports the fields that differ. to defaults to now.
+func (s *Server) GetTaskDiff(w http.ResponseWriter, r *http.Request, ID string) {
+	if !s.checkQuery(w, r, []string{"from", "to"}) {
+		return
+	}
+
+	if r.URL.Query().Get("from") == "" {
+		http.Error(w, "from is required", http.StatusBadRequest)
+		return
+	}
+	from, err := parseDiffTime(r, "from", time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseDiffTime(r, "to", time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	history, complete, err := s.taskHistory(ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	toState, ok := stateAt(history, to)
+	if !ok {
+		http.Error(w, "no recorded state for this task at to", http.StatusNotFound)
+		return
+	}
+	fromState, fromKnown := stateAt(history, from)
+
+	result := TaskDiff{ID: ID, From: from, To: to, FromKnown: fromKnown}
+	if fromKnown {
+		result.Diff = diffTasks(fromState, toState)
+	} else {
+		result.Diff = diffFields(nil, taskFields(toState))
+	}
+	if !fromKnown && !complete {
+		w.Header().Set("Warning", `199 - "history before the oldest retained change is not available"`)
+	}
+
+	writeEncoded(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const icalTimeFormat = "20060102T150405Z"
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func (s *Server) handleICal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetICal(w, r)
+}
+
+func (s *Server) GetICal(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, []string{"assignee"}) {
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(TaskFilter{Assignee: r.URL.Query().Get("assignee")})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	sortTasks(tasks, SortSpec{Field: "due_at"})
+	s.canonicalStatuses(tasks)
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write(renderICal(tasks))
+}
+
+// renderICal writes tasks with a due date as RFC 5545 VTODO components.
+func renderICal(tasks []Task) []byte {
+	var buf bytes.Buffer
+
+	writeICalLine(&buf, "BEGIN:VCALENDAR")
+	writeICalLine(&buf, "VERSION:2.0")
+	writeICalLine(&buf, "PRODID:-//httpPractice//tasks//EN")
+
+	for _, task := range tasks {
+		if task.DueAt == nil {
+			continue
+		}
+
+		writeICalLine(&buf, "BEGIN:VTODO")
+		writeICalLine(&buf, "UID:"+icalEscaper.Replace(task.ID)+"@httppractice")
+		writeICalLine(&buf, "DTSTAMP:"+task.UpdatedAt.UTC().Format(icalTimeFormat))
+		writeICalLine(&buf, "CREATED:"+task.CreatedAt.UTC().Format(icalTimeFormat))
+		writeICalLine(&buf, "LAST-MODIFIED:"+task.UpdatedAt.UTC().Format(icalTimeFormat))
+		writeICalLine(&buf, "DUE:"+task.DueAt.UTC().Format(icalTimeFormat))
+		writeICalLine(&buf, "SUMMARY:"+icalEscaper.Replace(task.Title))
+		writeICalLine(&buf, "STATUS:"+icalStatus(task.Status))
+		if len(task.Tags) > 0 {
+			escaped := make([]string, len(task.Tags))
+			for i, tag := range task.Tags {
+				escaped[i] = icalEscaper.Replace(tag)
+			}
+			writeICalLine(&buf, "CATEGORIES:"+strings.Join(escaped, ","))
+		}
+		writeICalLine(&buf, "END:VTODO")
+	}
+
+	writeICalLine(&buf, "END:VCALENDAR")
+
+	return buf.Bytes()
+}
+
+func icalStatus(status string) string {
+	switch status {
+	case "in_progress":
+		return "IN-PROCESS"
+	case "done":
+		return "COMPLETED"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+// writeICalLine folds content lines longer than 75 octets as RFC 5545
+// requires, without splitting a UTF-8 sequence.
+func writeICalLine(buf *bytes.Buffer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space, which counts towards the limit
+		limit = 74
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Imports hold the write lock for the whole batch, so only a few may run
+	// at once; the rest are turned away rather than queued. Async imports
+	// take their slot in the background instead, see startImport.
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); !async {
+		select {
+		case s.imports <- struct{}{}:
+			defer func() { <-s.imports }()
+		default:
+			s.setRetryAfter(w, r, time.Second)
+			http.Error(w, "too many concurrent imports", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	s.ImportTasks(w, r)
+}
+
+// decodeImport reads either a JSON array of tasks or NDJSON, the format
+// produced by GET /tasks/export.
+func decodeImport(r *http.Request) ([]Task, error) {
+	var tasks []Task
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-ndjson" {
+		err := decodeVersioned(r, &tasks)
+		return tasks, err
+	}
+
+	migrate, err := schemaMigration(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := newBodyDecoder(r.Body)
+	for {
+		var task Task
+		if err := decodeMigrated(dec.Decode, &task, migrate); errors.Is(err, io.EOF) {
+			return tasks, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("line %d: %w", len(tasks)+1, err)
+		}
+		tasks = append(tasks, task)
+	}
+}
+
+// ImportTasks creates tasks in bulk. Rows whose external_id is already known
+// are skipped, or updated with ?on_existing=update, so re-running an import
+// does not create duplicates. With ?async=true it answers 202 and runs as an
+// operation polled at /operations/{id}.
+func (s *Server) ImportTasks(w http.ResponseWriter, r *http.Request) {
+	var updateExisting bool
+
+	switch onExisting := r.URL.Query().Get("on_existing"); onExisting {
+	case "", "skip":
+	case "update":
+		updateExisting = true
+	default:
+		http.Error(w, fmt.Sprintf("unsupported on_existing %q", onExisting), http.StatusBadRequest)
+		return
+	}
+
+	async := false
+	if v := r.URL.Query().Get("async"); v != "" {
+		var err error
+		if async, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "async must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tasks, err := decodeImport(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	for i, task := range tasks {
+		if errs := validateTask(task); len(errs) > 0 {
+			http.Error(w, fmt.Sprintf("task %d: %s", i, strings.Join(errs, "; ")), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !checkLimit(w, "batch size", s.Config.BatchLimit, len(tasks), http.StatusRequestEntityTooLarge) {
+		return
+	}
+	if !checkLimit(w, "tags per task", s.Config.TagLimit, maxTaskTags(tasks), http.StatusUnprocessableEntity) {
+		return
+	}
+
+	if !s.checkTaskAssignees(w, tasks) {
+		return
+	}
+
+	s.applyDefaultPriorities(tasks)
+
+	if async {
+		op := s.startImport(tasks, updateExisting)
+		w.Header().Set("Location", operationLocation(op.ID))
+		writeEncoded(w, r, http.StatusAccepted, op)
+		return
+	}
+
+	result, err := s.DB.ImportTasks(tasks, updateExisting)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchOp is one RFC 6902 operation.
+type PatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	limit := length
+	if appending {
+		limit++
+	}
+	if err != nil || i < 0 || i >= limit || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return i, nil
+}
+
+func pointerGet(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+	}
+	return doc, nil
+}
+
+// pointerSet writes value at tokens, inserting into arrays when insert is
+// set and replacing otherwise. A nil value removes the target. It returns
+// the updated document since array edits may reallocate.
+func pointerSet(doc interface{}, tokens []string, value interface{}, insert, remove bool) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if len(rest) > 0 {
+			child, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			updated, err := pointerSet(child, rest, value, insert, remove)
+			if err != nil {
+				return nil, err
+			}
+			node[token] = updated
+			return node, nil
+		}
+		if _, ok := node[token]; !ok && !insert {
+			return nil, fmt.Errorf("path member %q not found", token)
+		}
+		if remove {
+			delete(node, token)
+		} else {
+			node[token] = value
+		}
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(node), insert && len(rest) == 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			updated, err := pointerSet(node[i], rest, value, insert, remove)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = updated
+			return node, nil
+		}
+		switch {
+		case remove:
+			return append(node[:i], node[i+1:]...), nil
+		case insert:
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		default:
+			node[i] = value
+			return node, nil
+		}
+	default:
+		return nil, fmt.Errorf("path member %q not found", token)
+	}
+}
+
+// applyJSONPatch applies ops in order to a decoded JSON document. The first
+// failing op aborts the patch.
+func applyJSONPatch(doc interface{}, ops []PatchOp) (interface{}, error) {
+	for i, op := range ops {
+		path, err := parsePointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("op %d: %w", i, err)
+		}
+
+		var value interface{}
+		if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+			if op.Value == nil {
+				return nil, fmt.Errorf("op %d: value is required", i)
+			}
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, fmt.Errorf("op %d: %w", i, err)
+			}
+		}
+
+		switch op.Op {
+		case "add":
+			doc, err = pointerSet(doc, path, value, true, false)
+		case "remove":
+			doc, err = pointerSet(doc, path, nil, false, true)
+		case "replace":
+			doc, err = pointerSet(doc, path, value, false, false)
+		case "test":
+			var current interface{}
+			if current, err = pointerGet(doc, path); err == nil && !reflect.DeepEqual(current, value) {
+				err = fmt.Errorf("test failed at %q", op.Path)
+			}
+		case "move", "copy":
+			var from []string
+			if from, err = parsePointer(op.From); err != nil {
+				break
+			}
+			if value, err = pointerGet(doc, from); err != nil {
+				break
+			}
+			if op.Op == "move" {
+				if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+					err = errors.New("cannot move a value into itself")
+					break
+				}
+				if doc, err = pointerSet(doc, from, nil, false, true); err != nil {
+					break
+				}
+			} else {
+				value = deepCopyJSON(value)
+			}
+			doc, err = pointerSet(doc, path, value, true, false)
+		default:
+			err = fmt.Errorf("unsupported op %q", op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("op %d: %w", i, err)
+		}
+	}
+	return doc, nil
+}
+
+func deepCopyJSON(value interface{}) interface{} {
+	data, _ := json.Marshal(value)
+	var copied interface{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+// readOnlyFields may not be changed by a patch; the server owns them.
+var readOnlyFields = []string{
+	"id", "created_at", "updated_at", "archived_at", "archive_reason", "merged_into",
+	"started_at", "completed_at", "lead_time_seconds", "cycle_time_seconds",
+	"locked_by", "locked_at", "lock_expires_at", "checksum", "remaining_minutes",
+}
+
+type PatchPreview struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"`
+	Task   *Task    `json:"task,omitempty"`
+}
+
+// PreviewPatch applies an RFC 6902 patch to a copy of the stored task and
+// returns the result with any validation errors. Nothing is persisted. An
+// archived task answers 409, as an update of it would.
+func (s *Server) PreviewPatch(w http.ResponseWriter, r *http.Request, ID string) {
+	var ops []PatchOp
+	if err := decodeJSON(r.Body, &ops); err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := s.DB.GetTask(ID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if stored.ArchivedAt != nil {
+		http.Error(w, ErrArchived.Error(), http.StatusConflict)
+		return
+	}
+
+	data, _ := json.Marshal(stored)
+	var doc interface{}
+	json.Unmarshal(data, &doc)
+	before := deepCopyJSON(doc).(map[string]interface{})
+
+	patched, err := applyJSONPatch(doc, ops)
+	if err != nil {
+		writeEncoded(w, r, http.StatusOK, PatchPreview{Errors: []string{err.Error()}})
+		return
+	}
+
+	result, ok := patched.(map[string]interface{})
+	if !ok {
+		writeEncoded(w, r, http.StatusOK, PatchPreview{Errors: []string{"patch must leave the task a JSON object"}})
+		return
+	}
+
+	errs := []string{}
+	for _, field := range readOnlyFields {
+		if !reflect.DeepEqual(before[field], result[field]) {
+			errs = append(errs, fmt.Sprintf("%s cannot be changed", field))
+		}
+	}
+
+	// The fields the patch changed are what a PUT would send, so they go
+	// through the same checks as an update body.
+	changes := make(map[string]interface{})
+	for field, value := range result {
+		if !reflect.DeepEqual(before[field], value) {
+			changes[field] = value
+		}
+	}
+	if err := s.validateUpdate(changes); err != nil {
+		errs = append(errs, err.Error())
+	} else if tags, ok := changes["tags"]; ok {
+		result["tags"] = tags
+	}
+
+	var task Task
+	data, _ = json.Marshal(result)
+	if err := json.Unmarshal(data, &task); err != nil {
+		errs = append(errs, err.Error())
+		writeEncoded(w, r, http.StatusOK, PatchPreview{Errors: errs})
+		return
+	}
+	errs = append(errs, validateTask(task)...)
+
+	// Stamp the result as the write would: a new updated_at and the
+	// timestamps of a status transition.
+	if len(changes) > 0 {
+		now := s.clock()
+		task.UpdatedAt = now
+		if task.Status != stored.Status {
+			markTransition(&task, now)
+		}
+	}
+
+	presented := s.presentTask(r, task)
+	writeEncoded(w, r, http.StatusOK, PatchPreview{Valid: len(errs) == 0, Errors: errs, Task: &presented})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lifecycle tracks the background jobs of the server. Every job gets a
+// context that is cancelled on Shutdown, which then waits for the jobs to
+// return so in-flight work such as queued notifications can finish.
+type Lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mx      sync.Mutex
+	running map[string]int
+}
+
+func NewLifecycle() *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{ctx: ctx, cancel: cancel, running: make(map[string]int)}
+}
+
+// Go starts fn as a named background job.
+func (l *Lifecycle) Go(name string, fn func(ctx context.Context)) {
+	l.mx.Lock()
+	l.running[name]++
+	l.mx.Unlock()
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer func() {
+			l.mx.Lock()
+			l.running[name]--
+			if l.running[name] == 0 {
+				delete(l.running, name)
+			}
+			l.mx.Unlock()
+		}()
+
+		fn(l.ctx)
+	}()
+}
+
+// Shutdown cancels every job and waits up to timeout for them to return.
+// The error names the jobs still running when the timeout hit.
+func (l *Lifecycle) Shutdown(timeout time.Duration) error {
+	l.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	names := make([]string, 0, len(l.running))
+	for name := range l.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("background jobs still running after %v: %s", timeout, strings.Join(names, ", "))
+}
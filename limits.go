@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// SoftLimit pairs a hard limit that rejects with a lower soft one that
+// only warns, so clients hear about a limit before they run into it.
+// Zero leaves either side unset.
+type SoftLimit struct {
+	Soft int `json:"soft"`
+	Hard int `json:"hard"`
+}
+
+func (l SoftLimit) validate() error {
+	if l.Soft < 0 || l.Hard < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if l.Soft > 0 && l.Hard > 0 && l.Soft >= l.Hard {
+		return fmt.Errorf("soft limit %d must be below the hard limit %d", l.Soft, l.Hard)
+	}
+	return nil
+}
+
+// checkLimit answers status when n is over the hard limit and adds a
+// Warning header when it is only over the soft one. It reports whether the
+// handler may continue.
+func checkLimit(w http.ResponseWriter, what string, limit SoftLimit, n int, status int) bool {
+	if err := limit.check(what, n); err != nil {
+		http.Error(w, err.Error(), status)
+		return false
+	}
+
+	if limit.Soft > 0 && n > limit.Soft {
+		advice := "no hard limit is set"
+		if limit.Hard > 0 {
+			advice = fmt.Sprintf("requests above %d will be rejected", limit.Hard)
+		}
+		w.Header().Add("Warning", fmt.Sprintf(`199 - "%s: %d is above the soft limit of %d, %s"`, what, n, limit.Soft, advice))
+	}
+	return true
+}
+
+// check reports n above the hard limit as an error. The soft limit only
+// produces a warning, which checkLimit adds to the response.
+func (limit SoftLimit) check(what string, n int) error {
+	if limit.Hard > 0 && n > limit.Hard {
+		return fmt.Errorf("%s: %d exceeds the limit of %d", what, n, limit.Hard)
+	}
+	return nil
+}
+
+func maxTaskTags(tasks []Task) int {
+	n := 0
+	for _, task := range tasks {
+		n = max(n, len(task.Tags))
+	}
+	return n
+}
+
+// updateTagCount is the number of tags an update body sets, or zero.
+func updateTagCount(data map[string]interface{}) int {
+	tags, _ := data["tags"].([]interface{})
+	return len(tags)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultLockTTL = 5 * time.Minute
+
+// lockOwner returns who holds the task's lock at now, or "" when it is not
+// locked or the lock has expired.
+func (t *Task) lockOwner(now time.Time) string {
+	if t.LockedBy == "" || t.LockExpiresAt == nil || !now.Before(*t.LockExpiresAt) {
+		return ""
+	}
+	return t.LockedBy
+}
+
+func (t *Task) clearLock() {
+	t.LockedBy = ""
+	t.LockedAt = nil
+	t.LockExpiresAt = nil
+}
+
+type LockRequest struct {
+	Owner      string `json:"owner"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// LockTask serves POST /tasks/{id}/lock and /unlock. The lock keeps two
+// workers from picking up the same task; it lapses on its own after the TTL.
+func (s *Server) LockTask(w http.ResponseWriter, r *http.Request, ID string, lock bool) {
+	var req LockRequest
+
+	if err := requestCodec(r).Decode(r.Body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.Owner == "" {
+		http.Error(w, "owner is required", http.StatusBadRequest)
+		return
+	}
+	if req.TTLSeconds < 0 {
+		http.Error(w, "ttl_seconds must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	ttl := defaultLockTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	var task *Task
+	var err error
+	if lock {
+		task, err = s.DB.LockTask(ID, req.Owner, s.clock(), ttl)
+	} else {
+		task, err = s.DB.UnlockTask(ID, req.Owner, s.clock())
+	}
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, ErrLocked) || errors.Is(err, ErrArchived) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, s.presentTask(r, *task))
+}
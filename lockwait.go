@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	lockWaitWindow     = 10 * time.Second
+	lockWaitMaxSamples = 1024
+)
+
+type lockWaitSample struct {
+	at   time.Time
+	wait time.Duration
+}
+
+// lockWaitStats keeps the most recent lock waits in a ring buffer so the
+// average can be computed over a sliding time window.
+type lockWaitStats struct {
+	mx      sync.Mutex
+	samples []lockWaitSample
+	next    int
+}
+
+func (s *lockWaitStats) record(wait time.Duration) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	sample := lockWaitSample{at: time.Now(), wait: wait}
+	if len(s.samples) < lockWaitMaxSamples {
+		s.samples = append(s.samples, sample)
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % lockWaitMaxSamples
+}
+
+func (s *lockWaitStats) average(window time.Duration) time.Duration {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	since := time.Now().Add(-window)
+	var (
+		total time.Duration
+		count int
+	)
+	for _, sample := range s.samples {
+		if sample.at.After(since) {
+			total += sample.wait
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
+
+func (db *MapDB) lock() {
+	start := time.Now()
+	db.mx.Lock()
+	db.lockWaits.record(time.Since(start))
+}
+
+func (db *MapDB) rlock() {
+	start := time.Now()
+	db.mx.RLock()
+	db.lockWaits.record(time.Since(start))
+}
+
+func (db *MapDB) LockWaitAverage() time.Duration {
+	return db.lockWaits.average(lockWaitWindow)
+}
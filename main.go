@@ -1,13 +1,20 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -20,50 +27,245 @@ import (
 */
 
 func main() {
-	mux := http.NewServeMux()
+	configPath := flag.String("config", "", "path to a JSON config file")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Config error: %v\n", err)
+	}
+
+	db := NewMapDB()
+	if cfg.ChangeSeqFile != "" {
+		if err := db.PersistChangeSeq(cfg.ChangeSeqFile); err != nil {
+			log.Fatalf("Change log error: %v\n", err)
+		}
+	}
+
+	jobs := NewLifecycle()
+
+	server := Server{
+		DB:         db,
+		Config:     cfg,
+		imports:    make(chan struct{}, cfg.MaxConcurrentImports),
+		jobs:       jobs,
+		operations: newOperationStore(time.Duration(cfg.OperationTTLSeconds) * time.Second),
+	}
+	server.readOnly.Store(cfg.ReadOnly)
+	server.toggleReadOnlyOnSignal(jobs)
+
+	var notifiers []Notifier
+	for _, name := range cfg.Notifiers {
+		notifier, _ := notifierByName(name)
+		notifiers = append(notifiers, notifier)
+	}
+	if len(notifiers) == 0 {
+		notifiers = append(notifiers, NopNotifier{})
+	}
+	hub := NewNotifierHub(cfg.NotifyBuffer, cfg.NotifyBatchSize, time.Duration(cfg.NotifyTimeoutMS)*time.Millisecond, notifiers...)
+	jobs.Go("notifier hub", hub.Run)
+	if subscriber, ok := server.DB.(ChangeSubscriber); ok {
+		subscriber.OnChange(hub.publishChange)
+	}
 
-	server := Server{DB: nil}
+	if cfg.ReadOnly {
+		log.Println("starting in read-only mode, send SIGUSR1 to toggle")
+	}
+
+	if len(cfg.ForcedErrors) > 0 {
+		if cfg.Debug {
+			log.Printf("DEBUG: failure injection is enabled for %d task IDs, do not run this config in production\n", len(cfg.ForcedErrors))
+		} else {
+			log.Println("forced_errors is set but debug is off, failure injection is disabled")
+		}
+	}
+
+	server.warmUp(jobs, func() error {
+		_, err := loadLocation(cfg.Timezone)
+		return err
+	})
 
-	mux.HandleFunc("/tasks", server.handleTasks)
-	mux.HandleFunc("/tasks/", server.handleTaskByID)
+	httpServer := &http.Server{Addr: cfg.Addr, Handler: server.routes()}
 
-	log.Println("server has started")
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
-	if err := http.ListenAndServe("localhost:8080", mux); err != nil {
-		log.Printf("Server error: %v\n", err)
+	go func() {
+		log.Println("server has started")
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Server error: %v\n", err)
+			stop <- syscall.SIGTERM
+		}
+	}()
+
+	<-stop
+	log.Println("shutting down")
+
+	// Both steps share one deadline, so shutdown as a whole stays within
+	// shutdown_timeout_ms.
+	deadline := time.Now().Add(time.Duration(cfg.ShutdownTimeoutMS) * time.Millisecond)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown error: %v\n", err)
+	}
+	if err := jobs.Shutdown(time.Until(deadline)); err != nil {
+		log.Printf("Shutdown error: %v\n", err)
 	}
 }
 
+// routes registers every endpoint and wraps the mux in the request-wide
+// middleware.
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/tasks", s.handleTasks)
+	mux.HandleFunc("/tasks/", s.handleTaskByID)
+	mux.HandleFunc("/tasks/validate-batch", s.handleValidateBatch)
+	mux.HandleFunc("/tasks/export", s.handleExport)
+	mux.HandleFunc("/tasks/reassign", s.handleReassign)
+	mux.HandleFunc("/tasks/workload", s.handleWorkload)
+	mux.HandleFunc("/tasks/changes", s.handleChanges)
+	mux.HandleFunc("/tasks.ics", s.handleICal)
+	mux.HandleFunc("/tasks/checksums", s.handleChecksums)
+	mux.HandleFunc("/tasks/import", s.handleImport)
+	mux.HandleFunc("/tasks/next", s.handleNext)
+	mux.HandleFunc("/tasks/batch-update", s.handleBatchUpdate)
+	mux.HandleFunc("/tasks/group-count", s.handleGroupCount)
+	mux.HandleFunc("/tasks/tag", s.handleBulkTag)
+	mux.HandleFunc("/tasks/ordered", s.handleOrdered)
+	mux.HandleFunc("/tasks/trend", s.handleTrend)
+	mux.HandleFunc("/tasks/assignees", s.handleAssignees)
+	mux.HandleFunc("/tasks/swap", s.handleSwap)
+	mux.HandleFunc("/tasks/bulk-archive-by-filter", s.handleBulkArchive)
+	mux.HandleFunc("/tasks/flow-metrics", s.handleFlowMetrics)
+	mux.HandleFunc("/capabilities", s.handleCapabilities)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/admin/tasks/", s.requireAdmin(s.handleAdminTaskByID))
+	mux.HandleFunc("/admin/compact", s.requireAdmin(s.handleAdminCompact))
+	mux.HandleFunc("/operations/", s.handleOperation)
+
+	return s.withTimezone(s.withMaintenance(s.withReadOnly(withFields(mux))))
+}
+
 type Task struct {
-	ID         string    `json:"id"`
-	Title      string    `json:"title"`
-	Status     string    `json:"status"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	ArchivedAt time.Time `json:"archived_at"`
+	ID            string     `json:"id"`
+	Title         string     `json:"title"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	ArchivedAt    *time.Time `json:"archived_at,omitempty"`
+	ArchiveReason string     `json:"archive_reason,omitempty"`
+	MergedInto    string     `json:"merged_into,omitempty"`
+	ExternalID    string     `json:"external_id"`
+	Tags          []string   `json:"tags,omitempty"`
+	Assignee      string     `json:"assignee"`
+	DueAt         *time.Time `json:"due_at,omitempty"`
+	Priority      int        `json:"priority"`
+	Position      int        `json:"position"`
+	DependsOn     []string   `json:"depends_on,omitempty"`
+
+	LockedBy      string     `json:"locked_by,omitempty"`
+	LockedAt      *time.Time `json:"locked_at,omitempty"`
+	LockExpiresAt *time.Time `json:"lock_expires_at,omitempty"`
+
+	EstimateMinutes  int `json:"estimate_minutes"`
+	SpentMinutes     int `json:"spent_minutes"`
+	RemainingMinutes int `json:"remaining_minutes"`
+
+	LeadTimeSeconds  *int64 `json:"lead_time_seconds,omitempty"`
+	CycleTimeSeconds *int64 `json:"cycle_time_seconds,omitempty"`
+
+	Checksum string `json:"checksum,omitempty"`
+
+	timeFormat  string
+	emptyFields string
+
+	// prioritySet records that a decoded body named a priority, so an
+	// explicit 0 can be told apart from an omitted one.
+	prioritySet bool
+}
+
+// InitialStatus is the status a new task starts in when it names none.
+const InitialStatus = "created"
+
+type TaskFilter struct {
+	IncludeArchived bool
+	Status          string
+	Assignee        string
+	Tag             string
+	DueFrom         time.Time
+	DueTo           time.Time
+
+	// StatusAliases makes Status match legacy stored statuses under their
+	// canonical name.
+	StatusAliases map[string]string
+
+	// ExcludeLocked drops tasks holding a lock that is still live at Now.
+	ExcludeLocked bool
+	Now           time.Time
 }
 
 type Saver interface {
-	AddTasks(data []Task) error
-	GetTasks() ([]Task, error)
+	AddTasks(data []Task) ([]Task, error)
+	GetTasks(filter TaskFilter) ([]Task, error)
 	GetTask(ID string) (*Task, error)
+	GetTaskByExternalID(externalID string) (*Task, error)
 	UpdateTask(data map[string]interface{}, ID string) (*Task, error)
-	ArchiveTask(ID string) error
+	ArchiveTask(ID string, reason string) error
+	TouchTask(ID string, at time.Time) (*Task, error)
+	AddSpentMinutes(ID string, minutes int) (*Task, error)
+	GetChanges(sinceSeq uint64, limit int) ([]Change, uint64, error)
+	GetTaskHistory(ID string) ([]Change, bool, error)
+	ReassignTasks(filter TaskFilter, to string) (int, error)
+	UpsertTasksByExternalID(data []Task) ([]Task, int, error)
+	ImportTasks(data []Task, updateExisting bool) (ImportResult, error)
+	MergeTasks(sourceID, targetID string, maxTags int) (*Task, error)
+	SwapTaskPositions(aID, bID string) ([]Task, error)
+	BulkTagTasks(filter TaskFilter, add, remove []string, maxTags int) (int, int, error)
+	BulkArchiveTasks(filter TaskFilter, reason string, confirm int) (int, error)
+	LockTask(ID, owner string, now time.Time, ttl time.Duration) (*Task, error)
+	UnlockTask(ID, owner string, now time.Time) (*Task, error)
+	BatchUpdateTasks(items []BatchUpdateItem, atomic bool) ([]BatchUpdateOutcome, error)
 }
 
 type Server struct {
-	DB Saver
+	DB     Saver
+	Config Config
+
+	imports   chan struct{}
+	readOnly  atomic.Bool
+	warmingUp atomic.Bool
+
+	jobs       *Lifecycle
+	operations *operationStore
+
+	now func() time.Time
 }
 
 var (
-	ErrNotFound = errors.New("not found")
-	ErrIsExist  = errors.New("this data is already exists")
+	ErrNotFound     = errors.New("not found")
+	ErrIsExist      = errors.New("this data is already exists")
+	ErrInvalidTime  = errors.New("invalid time")
+	ErrInvalidValue = errors.New("invalid value")
+	ErrArchived     = errors.New("task is archived")
+	ErrStale        = errors.New("task was modified since the given checksum")
+	ErrNonFinite    = errors.New("number must be finite")
+	ErrLocked       = errors.New("task is locked by someone else")
+	ErrOtherColumn  = errors.New("tasks are in different statuses")
+	ErrUnconfirmed  = errors.New("confirm does not match the number of matching tasks")
+	ErrTooManyTags  = errors.New("tags per task")
 )
 
 func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		s.GetTasks(w)
+		s.GetTasks(w, r)
+	case http.MethodHead:
+		s.HeadTasks(w, r)
 	case http.MethodPost:
 		s.AddTasks(w, r)
 	default:
@@ -71,74 +273,329 @@ func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) GetTasks(w http.ResponseWriter) {
-	tasks, err := s.DB.GetTasks()
+func (s *Server) parseTaskFilter(r *http.Request) (TaskFilter, error) {
+	filter := TaskFilter{StatusAliases: s.Config.StatusAliases}
+
+	if v := r.URL.Query().Get("include_archived"); v != "" {
+		includeArchived, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("include_archived must be a boolean")
+		}
+		filter.IncludeArchived = includeArchived
+	}
+
+	filter.Status = r.URL.Query().Get("status")
+	filter.Assignee = r.URL.Query().Get("assignee")
+	filter.Tag = r.URL.Query().Get("tag")
+
+	if v := r.URL.Query().Get("due_on"); v != "" {
+		from, to, err := localDay(v, requestLocation(r))
+		if err != nil {
+			return filter, errors.New("due_on must be a date in YYYY-MM-DD format")
+		}
+		filter.DueFrom, filter.DueTo = from, to
+	}
+
+	return filter, nil
+}
+
+// GetTasks lists tasks matching the query filters. An empty match is returned
+// as [] unless the client passes empty_is_error=true, in which case it is 404.
+func (s *Server) GetTasks(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, filterParams, paginationParams, []string{"external_id", "empty_is_error", "id_only", "exclude_locked"}) {
+		return
+	}
+
+	if externalID := r.URL.Query().Get("external_id"); externalID != "" {
+		s.GetTaskByExternalID(w, r, externalID)
+		return
+	}
+
+	filter, err := s.parseTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := r.URL.Query().Get("exclude_locked"); v != "" {
+		if filter.ExcludeLocked, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "exclude_locked must be a boolean", http.StatusBadRequest)
+			return
+		}
+		filter.Now = s.clock()
+	}
+
+	page, err := parsePage(r, s.Config.MaxOffset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = s.Config.DefaultSort
+	}
+	spec, err := parseSort(sortBy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	emptyIsError := false
+	if v := r.URL.Query().Get("empty_is_error"); v != "" {
+		if emptyIsError, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "empty_is_error must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+
+	idOnly := false
+	if v := r.URL.Query().Get("id_only"); v != "" {
+		if idOnly, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "id_only must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tasks, err := s.DB.GetTasks(filter)
 
 	if err != nil {
 		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasks)
+	if emptyIsError && len(tasks) == 0 {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	sortTasks(tasks, spec)
+
+	if page.IsSet() {
+		total := len(tasks)
+		var next string
+		tasks, next = paginateTasks(tasks, page, spec)
+		writePageHeaders(w, total, next)
+	}
+
+	if idOnly {
+		IDs := make([]string, len(tasks))
+		for i, task := range tasks {
+			IDs[i] = task.ID
+		}
+		writeTagged(w, r, IDs)
+		return
+	}
+
+	writeTagged(w, r, s.truncateResponse(w, r, tasks, s.presentTasks(r, tasks), spec))
 }
 
 func (s *Server) AddTasks(w http.ResponseWriter, r *http.Request) {
+	if bestEffort, _ := strconv.ParseBool(r.URL.Query().Get("best_effort")); bestEffort {
+		s.AddTasksBestEffort(w, r)
+		return
+	}
+
 	var tasks []Task
 
-	if err := json.NewDecoder(r.Body).Decode(&tasks); err != nil {
+	if err := decodeVersioned(r, &tasks); err != nil {
 		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	if err := s.DB.AddTasks(tasks); err != nil {
+	upsertBy := r.URL.Query().Get("upsert_by")
+	if upsertBy != "" && upsertBy != "external_id" {
+		http.Error(w, fmt.Sprintf("unsupported upsert_by %q", upsertBy), http.StatusBadRequest)
+		return
+	}
+
+	for i, task := range tasks {
+		errs := validateTask(task)
+		if upsertBy == "external_id" && task.ExternalID == "" {
+			errs = append(errs, "external_id is required for upsert_by=external_id")
+		}
+		if len(errs) > 0 {
+			http.Error(w, fmt.Sprintf("task %d: %s", i, strings.Join(errs, "; ")), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !checkLimit(w, "batch size", s.Config.BatchLimit, len(tasks), http.StatusRequestEntityTooLarge) {
+		return
+	}
+	if !checkLimit(w, "tags per task", s.Config.TagLimit, maxTaskTags(tasks), http.StatusUnprocessableEntity) {
+		return
+	}
+
+	if !s.checkTaskAssignees(w, tasks) {
+		return
+	}
+
+	if upsertBy == "external_id" {
+		s.UpsertTasksByExternalID(w, r, tasks)
+		return
+	}
+
+	s.applyDefaultPriorities(tasks)
+
+	created, err := s.DB.AddTasks(tasks)
+	if err != nil {
 		if errors.Is(err, ErrIsExist) {
-			http.Error(w, ErrIsExist.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		} else {
 			http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
 			return
 		}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasks)
+	writeWritten(w, r, http.StatusOK, tasksLocation(created), s.presentTasks(r, created))
+}
+
+func (s *Server) UpsertTasksByExternalID(w http.ResponseWriter, r *http.Request, tasks []Task) {
+	stored, created, err := s.DB.UpsertTasksByExternalID(tasks)
+	if errors.Is(err, ErrIsExist) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if errors.Is(err, ErrArchived) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if created > 0 {
+		status = http.StatusCreated
+	}
+	writeWritten(w, r, status, tasksLocation(stored), s.presentTasks(r, stored))
+}
+
+func (s *Server) GetTaskByExternalID(w http.ResponseWriter, r *http.Request, externalID string) {
+	task, err := s.DB.GetTaskByExternalID(externalID)
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, s.presentTask(r, *task))
 }
 
 func (s *Server) handleTaskByID(w http.ResponseWriter, r *http.Request) {
-	ID := strings.TrimPrefix(r.URL.Path, "/tasks/")
+	ID, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/")
 
 	if ID == "" {
 		http.Error(w, "ID is required", http.StatusBadRequest)
 		return
 	}
 
+	if action != "" {
+		s.handleTaskAction(w, r, ID, action)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
-		s.GetTask(w, ID)
+		s.GetTask(w, r, ID)
 	case http.MethodPut:
 		s.UpdateTask(w, r, ID)
 	case http.MethodDelete:
-		s.ArchiveTask(w, ID)
+		s.ArchiveTask(w, r, ID)
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (s *Server) GetTask(w http.ResponseWriter, ID string) {
+func (s *Server) handleTaskAction(w http.ResponseWriter, r *http.Request, ID string, action string) {
+	switch action {
+	case "similar":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.GetSimilarTasks(w, r, ID)
+	case "spent":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.AddSpentMinutes(w, r, ID)
+	case "diff":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.GetTaskDiff(w, r, ID)
+	case "patch/preview":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.PreviewPatch(w, r, ID)
+	case "lock", "unlock":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.LockTask(w, r, ID, action == "lock")
+	case "merge":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.MergeTask(w, r, ID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) GetTask(w http.ResponseWriter, r *http.Request, ID string) {
+	if code, ok := s.Config.ForcedErrors[ID]; ok && s.Config.Debug {
+		log.Printf("DEBUG: forcing status %d for task %q\n", code, ID)
+		http.Error(w, fmt.Sprintf("forced error for task %q", ID), code)
+		return
+	}
+
+	if r.URL.Query().Get("at") != "" {
+		s.GetTaskAt(w, r, ID)
+		return
+	}
+
 	task, err := s.DB.GetTask(ID)
 
 	if errors.Is(err, ErrNotFound) {
 		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
 		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(*task)
+	writeEncoded(w, r, http.StatusOK, s.presentTask(r, *task))
 }
 
 func (s *Server) UpdateTask(w http.ResponseWriter, r *http.Request, ID string) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.Config.MaxUpdateBytes)
+
 	var data = make(map[string]interface{})
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+	if err := decodeVersioned(r, &data); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("update body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.validateUpdate(data); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if !checkLimit(w, "tags per task", s.Config.TagLimit, updateTagCount(data), http.StatusUnprocessableEntity) {
+		return
 	}
 
 	task, err := s.DB.UpdateTask(data, ID)
@@ -146,70 +603,338 @@ func (s *Server) UpdateTask(w http.ResponseWriter, r *http.Request, ID string) {
 	if errors.Is(err, ErrNotFound) {
 		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
 		return
+	} else if errors.Is(err, ErrArchived) {
+		http.Error(w, ErrArchived.Error(), http.StatusConflict)
+		return
+	} else if errors.Is(err, ErrInvalidTime) || errors.Is(err, ErrInvalidValue) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if errors.Is(err, ErrNonFinite) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
 	} else if err != nil {
 		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(*task)
+	writeWritten(w, r, http.StatusCreated, taskLocation(task.ID), s.presentTask(r, *task))
 }
 
-func (s *Server) ArchiveTask(w http.ResponseWriter, ID string) {
-	err := s.DB.ArchiveTask(ID)
+func (s *Server) ArchiveTask(w http.ResponseWriter, r *http.Request, ID string) {
+	missingOK := s.Config.DeleteMissingOK
+	if v := r.URL.Query().Get("missing_ok"); v != "" {
+		var err error
+		if missingOK, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "missing_ok must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := s.DB.ArchiveTask(ID, r.URL.Query().Get("reason"))
 
-	if errors.Is(err, ErrNotFound) {
+	// With missing_ok, delete means "make sure it is gone" and an unknown ID
+	// already satisfies that.
+	if errors.Is(err, ErrNotFound) && missingOK {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if errors.Is(err, ErrNotFound) {
 		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
 	} else if err != nil {
 		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 type MapDB struct {
-	data map[string]*Task
-	mx   sync.RWMutex
+	data        map[string]*Task
+	externalIDs map[string]string
+	mx          sync.RWMutex
+	lockWaits   lockWaitStats
+	changes     changeLog
 }
 
-func (db *MapDB) AddTasks(newData []Task) error {
+func NewMapDB() *MapDB {
+	return &MapDB{
+		data:        make(map[string]*Task),
+		externalIDs: make(map[string]string),
+	}
+}
+
+func (db *MapDB) AddTasks(newData []Task) ([]Task, error) {
 	defer db.mx.Unlock()
-	db.mx.Lock()
+	db.lock()
+
+	batchExternalIDs := make(map[string]string)
+	for _, task := range newData {
+		if task.ExternalID == "" {
+			continue
+		}
+		if ID, ok := db.externalIDs[task.ExternalID]; ok && ID != task.ID {
+			return nil, fmt.Errorf("external_id %q: %w", task.ExternalID, ErrIsExist)
+		}
+		if ID, ok := batchExternalIDs[task.ExternalID]; ok && ID != task.ID {
+			return nil, fmt.Errorf("external_id %q: %w", task.ExternalID, ErrIsExist)
+		}
+		batchExternalIDs[task.ExternalID] = task.ID
+	}
+
+	created := make([]Task, 0, len(newData))
 	for _, task := range newData {
+		if old, ok := db.data[task.ID]; ok && old.ExternalID != "" {
+			delete(db.externalIDs, old.ExternalID)
+		}
 		task.CreatedAt = time.Now()
 		task.UpdatedAt = time.Now()
-		task.Status = "created"
+		startStatus(&task, task.CreatedAt)
 		db.data[task.ID] = &task
+		if task.ExternalID != "" {
+			db.externalIDs[task.ExternalID] = task.ID
+		}
+		db.changes.record(ChangeCreated, task)
+		created = append(created, task)
 	}
-	return nil
+	return created, nil
 }
 
-func (db *MapDB) GetTasks() ([]Task, error) {
-	var tasks []Task
+func (db *MapDB) UpsertTasksByExternalID(newData []Task) ([]Task, int, error) {
+	defer db.mx.Unlock()
+	db.lock()
+
+	seen := make(map[string]struct{})
+	for _, task := range newData {
+		if _, ok := seen[task.ExternalID]; ok {
+			return nil, 0, fmt.Errorf("external_id %q: %w", task.ExternalID, ErrIsExist)
+		}
+		seen[task.ExternalID] = struct{}{}
+
+		if ID, ok := db.externalIDs[task.ExternalID]; ok {
+			if db.data[ID].ArchivedAt != nil {
+				return nil, 0, fmt.Errorf("external_id %q: %w", task.ExternalID, ErrArchived)
+			}
+			continue
+		}
+		if _, ok := db.data[task.ID]; ok {
+			return nil, 0, fmt.Errorf("id %q: %w", task.ID, ErrIsExist)
+		}
+	}
+
+	now := time.Now()
+	stored := make([]Task, 0, len(newData))
+	created := 0
+	for _, task := range newData {
+		if ID, ok := db.externalIDs[task.ExternalID]; ok {
+			stored = append(stored, db.syncExternalLocked(db.data[ID], task, now))
+			continue
+		}
+
+		stored = append(stored, db.insertExternalLocked(task, now))
+		created++
+	}
+
+	return stored, created, nil
+}
 
+// syncExternalLocked overwrites the fields an external system owns. The
+// stored ID and CreatedAt are kept, and so is the priority when the row
+// names none. db.mx must be held for writing.
+func (db *MapDB) syncExternalLocked(existing *Task, task Task, now time.Time) Task {
+	before := *existing
+	existing.Title = task.Title
+	existing.Tags = task.Tags
+	existing.Assignee = task.Assignee
+	existing.DueAt = task.DueAt
+	if task.prioritySet {
+		existing.Priority = task.Priority
+	}
+	existing.DependsOn = task.DependsOn
+	existing.EstimateMinutes = task.EstimateMinutes
+	existing.SpentMinutes = task.SpentMinutes
+	if task.Status != "" {
+		existing.Status = task.Status
+		markTransition(existing, now)
+	}
+	existing.UpdatedAt = now
+	db.changes.recordDiff(ChangeUpdated, before, *existing)
+	return *existing
+}
+
+func (db *MapDB) insertExternalLocked(task Task, now time.Time) Task {
+	task.CreatedAt = now
+	task.UpdatedAt = now
+	startStatus(&task, now)
+	db.data[task.ID] = &task
+	if task.ExternalID != "" {
+		db.externalIDs[task.ExternalID] = task.ID
+	}
+	db.changes.record(ChangeCreated, task)
+	return task
+}
+
+func (db *MapDB) ImportTasks(newData []Task, updateExisting bool) (ImportResult, error) {
+	defer db.mx.Unlock()
+	db.lock()
+
+	var result ImportResult
+	now := time.Now()
+	for _, task := range newData {
+		if ID, ok := db.externalIDs[task.ExternalID]; ok && task.ExternalID != "" {
+			existing := db.data[ID]
+			if !updateExisting || existing.ArchivedAt != nil {
+				result.Skipped++
+				continue
+			}
+			db.syncExternalLocked(existing, task, now)
+			result.Updated++
+			continue
+		}
+
+		if _, ok := db.data[task.ID]; ok {
+			result.Skipped++
+			continue
+		}
+
+		db.insertExternalLocked(task, now)
+		result.Created++
+	}
+
+	return result, nil
+}
+
+func (filter TaskFilter) Match(task *Task) bool {
+	if task.ArchivedAt != nil && !filter.IncludeArchived {
+		return false
+	}
+	if filter.Status != "" && resolveStatus(filter.StatusAliases, task.Status) != resolveStatus(filter.StatusAliases, filter.Status) {
+		return false
+	}
+	if filter.Assignee != "" && task.Assignee != filter.Assignee {
+		return false
+	}
+	if filter.Tag != "" && !slices.Contains(task.Tags, filter.Tag) {
+		return false
+	}
+	if !filter.DueFrom.IsZero() && (task.DueAt == nil || task.DueAt.Before(filter.DueFrom) || !task.DueAt.Before(filter.DueTo)) {
+		return false
+	}
+	if filter.ExcludeLocked && task.lockOwner(filter.Now) != "" {
+		return false
+	}
+	return true
+}
+
+// GetTasks copies the matching tasks while holding the read lock and releases
+// it before the caller serializes them, so writers are not blocked by slow
+// clients.
+func (db *MapDB) GetTasks(filter TaskFilter) ([]Task, error) {
+	db.rlock()
+	defer db.mx.RUnlock()
+
+	tasks := make([]Task, 0, len(db.data))
 	for _, task := range db.data {
-		tasks = append(tasks, *task)
+		if filter.Match(task) {
+			tasks = append(tasks, *task)
+		}
 	}
 	return tasks, nil
 }
 
 func (db *MapDB) GetTask(ID string) (*Task, error) {
+	db.rlock()
+	defer db.mx.RUnlock()
+
 	task, ok := db.data[ID]
 	if !ok {
 		return nil, ErrNotFound
 	}
 
-	return task, nil
+	found := *task
+	return &found, nil
+}
+
+func (db *MapDB) GetTaskByExternalID(externalID string) (*Task, error) {
+	db.rlock()
+	defer db.mx.RUnlock()
+
+	ID, ok := db.externalIDs[externalID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	found := *db.data[ID]
+	return &found, nil
 }
 
 func (db *MapDB) UpdateTask(data map[string]interface{}, ID string) (*Task, error) {
-	task, err := db.GetTask(ID)
+	db.lock()
+	defer db.mx.Unlock()
 
+	task, ok := db.data[ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if task.ArchivedAt != nil {
+		return nil, ErrArchived
+	}
+
+	update, err := parseTaskUpdate(data)
 	if err != nil {
 		return nil, err
 	}
 
+	db.applyUpdateLocked(task, update, time.Now())
+
+	updated := *task
+	return &updated, nil
+}
+
+// taskUpdate is a validated update body, so a batch can check every item
+// before any of them is applied.
+type taskUpdate struct {
+	data map[string]interface{}
+
+	dueAt    *time.Time
+	estimate int
+	spent    int
+	priority int
+	position int
+
+	hasDueAt, hasEstimate, hasSpent, hasPriority, hasPosition bool
+}
+
+func parseTaskUpdate(data map[string]interface{}) (taskUpdate, error) {
+	update := taskUpdate{data: data}
+	var err error
+
+	_, update.hasDueAt = data["due_at"]
+	if update.dueAt, err = parseOptionalTime(data["due_at"]); err != nil {
+		return update, fmt.Errorf("%w: due_at: %v", ErrInvalidTime, err)
+	}
+
+	if update.estimate, update.hasEstimate, err = parseMinutes(data, "estimate_minutes"); err != nil {
+		return update, err
+	}
+	if update.spent, update.hasSpent, err = parseMinutes(data, "spent_minutes"); err != nil {
+		return update, err
+	}
+	if update.priority, update.hasPriority, err = parseMinutes(data, "priority"); err != nil {
+		return update, err
+	}
+	if update.position, update.hasPosition, err = parseMinutes(data, "position"); err != nil {
+		return update, err
+	}
+
+	return update, nil
+}
+
+// applyUpdateLocked writes a parsed update to a stored task and records the
+// change. db.mx must be held for writing.
+func (db *MapDB) applyUpdateLocked(task *Task, update taskUpdate, now time.Time) {
+	before := *task
+	data := update.data
+
 	title, ok := data["title"].(string)
 	if ok {
 		task.Title = title
@@ -218,27 +943,410 @@ func (db *MapDB) UpdateTask(data map[string]interface{}, ID string) (*Task, erro
 	status, ok := data["status"].(string)
 	if ok {
 		task.Status = status
+		markTransition(task, now)
+	}
+
+	assignee, ok := data["assignee"].(string)
+	if ok {
+		task.Assignee = assignee
+	}
+
+	if rawTags, ok := data["tags"].([]interface{}); ok {
+		tags := make([]string, 0, len(rawTags))
+		for _, rawTag := range rawTags {
+			if tag, ok := rawTag.(string); ok {
+				tags = append(tags, tag)
+			}
+		}
+		task.Tags = tags
+	}
+
+	if rawDeps, ok := data["depends_on"].([]interface{}); ok {
+		deps := make([]string, 0, len(rawDeps))
+		for _, rawDep := range rawDeps {
+			if dep, ok := rawDep.(string); ok && dep != task.ID {
+				deps = append(deps, dep)
+			}
+		}
+		task.DependsOn = deps
 	}
-	task.UpdatedAt = time.Now()
 
-	db.mx.RLock()
-	db.data[ID] = task
-	db.mx.RUnlock()
+	if update.hasDueAt {
+		task.DueAt = update.dueAt
+	}
+	if update.hasEstimate {
+		task.EstimateMinutes = update.estimate
+	}
+	if update.hasSpent {
+		task.SpentMinutes = update.spent
+	}
+	if update.hasPriority {
+		task.Priority = update.priority
+	}
+	if update.hasPosition {
+		task.Position = update.position
+	}
+	task.UpdatedAt = now
 
-	return task, nil
+	db.changes.recordDiff(ChangeUpdated, before, *task)
 }
 
-func (db *MapDB) ArchiveTask(ID string) error {
-	task, err := db.GetTask(ID)
-	if err != nil {
-		return err
+func (db *MapDB) ArchiveTask(ID string, reason string) error {
+	db.lock()
+	defer db.mx.Unlock()
+
+	task, ok := db.data[ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if task.ArchivedAt != nil {
+		return nil
 	}
-	task.ArchivedAt = time.Now()
+	before := *task
+	archivedAt := time.Now()
+	task.ArchivedAt = &archivedAt
+	task.ArchiveReason = reason
 	task.Status = "archived"
 
-	db.mx.RLock()
-	db.data[ID] = task
-	db.mx.RUnlock()
+	db.changes.recordDiff(ChangeArchived, before, *task)
 
 	return nil
 }
+
+func (db *MapDB) TouchTask(ID string, at time.Time) (*Task, error) {
+	db.lock()
+	defer db.mx.Unlock()
+
+	task, ok := db.data[ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if at.Before(task.CreatedAt) {
+		return nil, fmt.Errorf("%w: updated_at is before created_at", ErrInvalidTime)
+	}
+
+	before := *task
+	task.UpdatedAt = at
+	db.changes.recordDiff(ChangeUpdated, before, *task)
+
+	touched := *task
+	return &touched, nil
+}
+
+func (db *MapDB) ReassignTasks(filter TaskFilter, to string) (int, error) {
+	db.lock()
+	defer db.mx.Unlock()
+
+	now := time.Now()
+	count := 0
+	for _, task := range db.data {
+		if task.ArchivedAt != nil || !filter.Match(task) {
+			continue
+		}
+		before := *task
+		task.Assignee = to
+		task.UpdatedAt = now
+		db.changes.recordDiff(ChangeUpdated, before, *task)
+		count++
+	}
+
+	return count, nil
+}
+
+// BulkTagTasks adds and removes tags on every task matching the filter in a
+// single write-locked pass. Only tasks whose tags actually change are
+// counted and get a change event. If any task would end up with more than
+// maxTags tags, nothing changes and ErrTooManyTags is returned; zero means
+// no limit. The largest resulting tag count is returned with the count.
+func (db *MapDB) BulkTagTasks(filter TaskFilter, add, remove []string, maxTags int) (int, int, error) {
+	db.lock()
+	defer db.mx.Unlock()
+
+	retagged := make(map[*Task][]string)
+	mostTags := 0
+	for _, task := range db.data {
+		if task.ArchivedAt != nil || !filter.Match(task) {
+			continue
+		}
+		tags, changed := retag(task.Tags, add, remove)
+		if !changed {
+			continue
+		}
+		if maxTags > 0 && len(tags) > maxTags {
+			return 0, 0, fmt.Errorf("%w: task %q: %d exceeds the limit of %d", ErrTooManyTags, task.ID, len(tags), maxTags)
+		}
+		retagged[task] = tags
+		mostTags = max(mostTags, len(tags))
+	}
+
+	now := time.Now()
+	for task, tags := range retagged {
+		before := *task
+		task.Tags = tags
+		task.UpdatedAt = now
+		db.changes.recordDiff(ChangeUpdated, before, *task)
+	}
+
+	return len(retagged), mostTags, nil
+}
+
+// BulkArchiveTasks archives every non-archived task matching the filter,
+// but only when exactly confirm tasks match. On a mismatch nothing is
+// archived and the actual count is returned with ErrUnconfirmed.
+func (db *MapDB) BulkArchiveTasks(filter TaskFilter, reason string, confirm int) (int, error) {
+	db.lock()
+	defer db.mx.Unlock()
+
+	var matched []*Task
+	for _, task := range db.data {
+		if task.ArchivedAt == nil && filter.Match(task) {
+			matched = append(matched, task)
+		}
+	}
+	if len(matched) != confirm {
+		return len(matched), ErrUnconfirmed
+	}
+
+	now := time.Now()
+	for _, task := range matched {
+		before := *task
+		archivedAt := now
+		task.ArchivedAt = &archivedAt
+		task.ArchiveReason = reason
+		task.Status = "archived"
+		db.changes.recordDiff(ChangeArchived, before, *task)
+	}
+
+	return len(matched), nil
+}
+
+func parseOptionalTime(value interface{}) (*time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case time.Time:
+		return &v, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, err
+		}
+		return &parsed, nil
+	default:
+		return nil, fmt.Errorf("expected an RFC3339 string, got %T", value)
+	}
+}
+
+func (db *MapDB) AddSpentMinutes(ID string, minutes int) (*Task, error) {
+	db.lock()
+	defer db.mx.Unlock()
+
+	task, ok := db.data[ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if task.ArchivedAt != nil {
+		return nil, ErrArchived
+	}
+
+	before := *task
+	task.SpentMinutes += minutes
+	task.UpdatedAt = time.Now()
+	db.changes.recordDiff(ChangeUpdated, before, *task)
+
+	updated := *task
+	return &updated, nil
+}
+
+// MergeTasks folds the source task into the target: the target gains the
+// source's tags and the source is archived with a reference to the target.
+// A merge leaving the target with more than maxTags tags fails with
+// ErrTooManyTags; zero means no limit.
+func (db *MapDB) MergeTasks(sourceID, targetID string, maxTags int) (*Task, error) {
+	if sourceID == targetID {
+		return nil, fmt.Errorf("%w: cannot merge a task into itself", ErrInvalidValue)
+	}
+
+	db.lock()
+	defer db.mx.Unlock()
+
+	source, ok := db.data[sourceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	target, ok := db.data[targetID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if source.ArchivedAt != nil || target.ArchivedAt != nil {
+		return nil, ErrArchived
+	}
+
+	now := time.Now()
+
+	beforeTarget := *target
+	tags := slices.Clone(target.Tags)
+	for _, tag := range source.Tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if maxTags > 0 && len(tags) > maxTags {
+		return nil, fmt.Errorf("%w: %d exceeds the limit of %d", ErrTooManyTags, len(tags), maxTags)
+	}
+	target.Tags = tags
+	target.UpdatedAt = now
+	db.changes.recordDiff(ChangeUpdated, beforeTarget, *target)
+
+	beforeSource := *source
+	source.ArchivedAt = &now
+	source.ArchiveReason = "merged"
+	source.MergedInto = targetID
+	source.Status = "archived"
+	db.changes.recordDiff(ChangeArchived, beforeSource, *source)
+
+	merged := *target
+	return &merged, nil
+}
+
+// SwapTaskPositions exchanges the board positions of two active tasks in
+// the same status, under one write lock so no reader sees them half done.
+func (db *MapDB) SwapTaskPositions(aID, bID string) ([]Task, error) {
+	if aID == bID {
+		return nil, fmt.Errorf("%w: cannot swap a task with itself", ErrInvalidValue)
+	}
+
+	db.lock()
+	defer db.mx.Unlock()
+
+	a, ok := db.data[aID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, aID)
+	}
+	b, ok := db.data[bID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, bID)
+	}
+	if a.ArchivedAt != nil || b.ArchivedAt != nil {
+		return nil, ErrArchived
+	}
+	if a.Status != b.Status {
+		return nil, fmt.Errorf("%w: %q is %q, %q is %q", ErrOtherColumn, aID, a.Status, bID, b.Status)
+	}
+
+	now := time.Now()
+	beforeA, beforeB := *a, *b
+	a.Position, b.Position = b.Position, a.Position
+	a.UpdatedAt = now
+	b.UpdatedAt = now
+	db.changes.recordDiff(ChangeUpdated, beforeA, *a)
+	db.changes.recordDiff(ChangeUpdated, beforeB, *b)
+
+	return []Task{*a, *b}, nil
+}
+
+// BatchUpdateTasks checks every item before applying any. With atomic set a
+// single failure leaves all tasks untouched; otherwise the valid items are
+// applied and the rest report their error.
+func (db *MapDB) BatchUpdateTasks(items []BatchUpdateItem, atomic bool) ([]BatchUpdateOutcome, error) {
+	db.lock()
+	defer db.mx.Unlock()
+
+	outcomes := make([]BatchUpdateOutcome, len(items))
+	updates := make([]taskUpdate, len(items))
+	failed := false
+
+	for i, item := range items {
+		outcomes[i].ID = item.ID
+
+		task, ok := db.data[item.ID]
+		if !ok {
+			outcomes[i].Err = ErrNotFound
+		} else if task.ArchivedAt != nil {
+			outcomes[i].Err = ErrArchived
+		} else if item.Checksum != "" && item.Checksum != taskChecksum(*task) {
+			outcomes[i].Err = ErrStale
+		} else {
+			updates[i], outcomes[i].Err = parseTaskUpdate(item.Data)
+		}
+
+		if outcomes[i].Err != nil {
+			failed = true
+		}
+	}
+
+	if atomic && failed {
+		return outcomes, nil
+	}
+
+	now := time.Now()
+	for i, item := range items {
+		if outcomes[i].Err != nil {
+			continue
+		}
+		task := db.data[item.ID]
+		db.applyUpdateLocked(task, updates[i], now)
+
+		updated := *task
+		outcomes[i].Task = &updated
+	}
+
+	return outcomes, nil
+}
+
+// LockTask reserves a task for owner until now+ttl. The owner may renew its
+// own lock; an expired lock is free for anyone.
+func (db *MapDB) LockTask(ID, owner string, now time.Time, ttl time.Duration) (*Task, error) {
+	db.lock()
+	defer db.mx.Unlock()
+
+	task, ok := db.data[ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if task.ArchivedAt != nil {
+		return nil, ErrArchived
+	}
+	if current := task.lockOwner(now); current != "" && current != owner {
+		return nil, ErrLocked
+	}
+
+	before := *task
+	expiresAt := now.Add(ttl)
+	if task.lockOwner(now) != owner {
+		task.LockedBy = owner
+		task.LockedAt = &now
+	}
+	task.LockExpiresAt = &expiresAt
+	db.changes.recordDiff(ChangeUpdated, before, *task)
+
+	locked := *task
+	return &locked, nil
+}
+
+// UnlockTask releases owner's lock. Releasing a task that is not locked, or
+// whose lock has expired, succeeds.
+func (db *MapDB) UnlockTask(ID, owner string, now time.Time) (*Task, error) {
+	db.lock()
+	defer db.mx.Unlock()
+
+	task, ok := db.data[ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if current := task.lockOwner(now); current != "" && current != owner {
+		return nil, ErrLocked
+	}
+	if task.LockedBy == "" {
+		unlocked := *task
+		return &unlocked, nil
+	}
+
+	before := *task
+	task.clearLock()
+	db.changes.recordDiff(ChangeUpdated, before, *task)
+
+	unlocked := *task
+	return &unlocked, nil
+}
@@ -0,0 +1,2877 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// newTestServer wires a server over a fresh MapDB the way main does. The
+// configure func, if any, adjusts the default config first.
+func newTestServer(t testing.TB, configure func(cfg *Config)) (*Server, http.Handler) {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	jobs := NewLifecycle()
+	t.Cleanup(func() { jobs.Shutdown(5 * time.Second) })
+
+	db := NewMapDB()
+	s := &Server{
+		DB:         db,
+		Config:     cfg,
+		imports:    make(chan struct{}, cfg.MaxConcurrentImports),
+		jobs:       jobs,
+		operations: newOperationStore(time.Duration(cfg.OperationTTLSeconds) * time.Second),
+	}
+	s.readOnly.Store(cfg.ReadOnly)
+
+	return s, s.routes()
+}
+
+// do sends a request through h. headers are key, value pairs.
+func do(t testing.TB, h http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// mustDo is do for requests expected to answer status.
+func mustDo(t testing.TB, h http.Handler, status int, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := do(t, h, method, target, body, headers...)
+	if w.Code != status {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, target, w.Code, status, w.Body.String())
+	}
+	return w
+}
+
+func decodeBody(t testing.TB, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+}
+
+// createTasks posts a JSON array of tasks and returns them as stored.
+func createTasks(t testing.TB, h http.Handler, body string) []Task {
+	t.Helper()
+
+	var tasks []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks", body), &tasks)
+	return tasks
+}
+
+func TestValidateBatch(t *testing.T) {
+	_, h := newTestServer(t, nil)
+
+	tests := []struct {
+		name  string
+		body  string
+		valid []bool
+	}{
+		{"empty", `[]`, []bool{}},
+		{"per item", `[{"id":"a","title":"x"},{"id":"","title":""},{"id":"b/c","title":"y"}]`, []bool{true, false, false}},
+		{"wrong type", `[{"id":"a","title":"x"},{"id":"b","title":5},{"id":"c","title":"z"}]`, []bool{true, false, true}},
+		{"syntax error", `[{"id":"a","title":"x"}, {bad}]`, []bool{true, false}},
+		{"truncated", `[{"id":"a","title":"x"},`, []bool{true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/validate-batch", tt.body)
+
+			var results []ValidationResult
+			decodeBody(t, w, &results)
+			if len(results) != len(tt.valid) {
+				t.Fatalf("got %d results, want %d: %s", len(results), len(tt.valid), w.Body.String())
+			}
+			for i, result := range results {
+				if result.Valid != tt.valid[i] {
+					t.Errorf("item %d: valid %v, want %v (%v)", i, result.Valid, tt.valid[i], result.Errors)
+				}
+				if !result.Valid && len(result.Errors) == 0 {
+					t.Errorf("item %d: invalid without errors", i)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBatchRejectsNonArray(t *testing.T) {
+	_, h := newTestServer(t, nil)
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/validate-batch", `{"id":"a"}`)
+	mustDo(t, h, http.StatusMethodNotAllowed, http.MethodGet, "/tasks/validate-batch", "")
+}
+
+func TestArchivedTasksHiddenByDefault(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"keep"},{"id":"b","title":"drop"}]`)
+
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/b?reason=obsolete", "")
+
+	var active []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks", ""), &active)
+	if len(active) != 1 || active[0].ID != "a" {
+		t.Fatalf("active tasks = %+v, want only a", active)
+	}
+	if active[0].ArchivedAt != nil || active[0].ArchiveReason != "" {
+		t.Errorf("active task carries archive metadata: %+v", active[0])
+	}
+
+	var all []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?include_archived=true&sort=id", ""), &all)
+	if len(all) != 2 {
+		t.Fatalf("got %d tasks with include_archived, want 2", len(all))
+	}
+	archived := all[1]
+	if archived.ID != "b" || archived.ArchivedAt == nil || archived.ArchiveReason != "obsolete" {
+		t.Errorf("archived task = %+v, want archived_at and reason obsolete", archived)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?include_archived=maybe", "")
+}
+
+func TestSimilarTasks(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[
+		{"id":"a","title":"Fix login bug"},
+		{"id":"b","title":"fix the login bug"},
+		{"id":"c","title":"Write release notes"}
+	]`)
+
+	var similar []map[string]interface{}
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a/similar", ""), &similar)
+	if len(similar) != 1 || similar[0]["id"] != "b" {
+		t.Fatalf("similar = %v, want only b", similar)
+	}
+	if score, _ := similar[0]["score"].(float64); score < 0.5 || score > 1 {
+		t.Errorf("score = %v, want within [0.5, 1]", similar[0]["score"])
+	}
+
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a/similar?threshold=0", ""), &similar)
+	if len(similar) != 2 {
+		t.Errorf("threshold=0 returned %d tasks, want 2", len(similar))
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/a/similar?threshold=2", "")
+	mustDo(t, h, http.StatusNotFound, http.MethodGet, "/tasks/missing/similar", "")
+}
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 0},
+		{"same words", "Same, words!", 1},
+		{"one two", "two three", 1.0 / 3},
+		{"alpha", "beta", 0},
+	}
+	for _, tt := range tests {
+		if got := titleSimilarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("titleSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveIsIdempotent(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x"}]`)
+
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/a?reason=first", "")
+	first, err := s.DB.GetTask("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/a?reason=second", "")
+	second, err := s.DB.GetTask("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !second.ArchivedAt.Equal(*first.ArchivedAt) || second.ArchiveReason != "first" {
+		t.Errorf("repeated delete changed the archive: %v %q, want %v %q", second.ArchivedAt, second.ArchiveReason, first.ArchivedAt, "first")
+	}
+}
+
+func TestExportRanges(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"b","title":"second"},{"id":"a","title":"first"}]`)
+
+	full := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/export", "")
+	if got := full.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("Accept-Ranges = %q, want bytes", got)
+	}
+	lines := strings.Split(strings.TrimSpace(full.Body.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"id":"a"`) {
+		t.Fatalf("export is not one line per task in ID order:\n%s", full.Body.String())
+	}
+
+	part := mustDo(t, h, http.StatusPartialContent, http.MethodGet, "/tasks/export", "", "Range", "bytes=0-9")
+	if want := full.Body.String()[:10]; part.Body.String() != want {
+		t.Errorf("range body = %q, want %q", part.Body.String(), want)
+	}
+
+	mustDo(t, h, http.StatusRequestedRangeNotSatisfiable, http.MethodGet, "/tasks/export", "", "Range", "bytes=100000-")
+
+	csv := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/export?format=csv", "")
+	if got := csv.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("csv Content-Type = %q", got)
+	}
+	if rows := strings.Count(csv.Body.String(), "\n"); rows != 3 {
+		t.Errorf("csv has %d rows, want a header and 2 tasks", rows)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/export?format=xml", "")
+
+	etag := full.Header().Get("ETag")
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Fatalf("export ETag = %q, want a strong tag", etag)
+	}
+	mustDo(t, h, http.StatusPartialContent, http.MethodGet, "/tasks/export", "", "Range", "bytes=0-9", "If-Range", etag)
+
+	// Archiving changes the export without bumping updated_at, so a resume
+	// against the old tag must restart with the full body.
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/b", "")
+	resumed := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/export?include_archived=true", "", "Range", "bytes=0-9", "If-Range", etag)
+	if resumed.Body.String() == full.Body.String() || !strings.Contains(resumed.Body.String(), `"archived_at"`) {
+		t.Errorf("If-Range after an archive did not return the new full export:\n%s", resumed.Body.String())
+	}
+}
+
+func TestExternalIDLookup(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","external_id":"JIRA-1"}]`)
+
+	var task Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?external_id=JIRA-1", ""), &task)
+	if task.ID != "a" {
+		t.Errorf("lookup returned %q, want a", task.ID)
+	}
+
+	mustDo(t, h, http.StatusNotFound, http.MethodGet, "/tasks?external_id=JIRA-2", "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks", `[{"id":"b","title":"y","external_id":"JIRA-1"}]`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks", `[{"id":"c","title":"y","external_id":"X"},{"id":"d","title":"z","external_id":"X"}]`)
+}
+
+func TestCreateReturnsTasksInRequestOrder(t *testing.T) {
+	_, h := newTestServer(t, nil)
+
+	tasks := createTasks(t, h, `[{"id":"c","title":"x"},{"id":"a","title":"y"},{"id":"b","title":"z"}]`)
+
+	var ids []string
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+		if task.Status != InitialStatus || task.CreatedAt.IsZero() {
+			t.Errorf("task %s was not returned as stored: %+v", task.ID, task)
+		}
+	}
+	if got := strings.Join(ids, ","); got != "c,a,b" {
+		t.Errorf("order = %s, want c,a,b", got)
+	}
+}
+
+func TestAdminTouch(t *testing.T) {
+	_, disabled := newTestServer(t, nil)
+	mustDo(t, disabled, http.StatusForbidden, http.MethodPost, "/admin/tasks/a/touch", "")
+
+	s, h := newTestServer(t, func(cfg *Config) { cfg.AdminToken = "secret" })
+	created := createTasks(t, h, `[{"id":"a","title":"x","tags":["ops","api"],"assignee":"ann","priority":2,"estimate_minutes":30,"due_at":"2030-01-01T00:00:00Z"}]`)[0]
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"status":"in_progress"}`)
+	stored, err := s.DB.GetTask("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *stored
+
+	mustDo(t, h, http.StatusUnauthorized, http.MethodPost, "/admin/tasks/a/touch", "")
+	mustDo(t, h, http.StatusUnauthorized, http.MethodPost, "/admin/tasks/a/touch", "", "Authorization", "Bearer wrong")
+
+	auth := []string{"Authorization", "Bearer secret"}
+	at := created.CreatedAt.Add(time.Hour).UTC().Format(time.RFC3339Nano)
+
+	var touched Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/admin/tasks/a/touch", `{"updated_at":"`+at+`"}`, auth...), &touched)
+	if got := touched.UpdatedAt.UTC().Format(time.RFC3339Nano); got != at {
+		t.Errorf("updated_at = %s, want %s", got, at)
+	}
+
+	// Apart from updated_at the stored task is byte-for-byte the same.
+	after, err := s.DB.GetTask("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after.UpdatedAt = original.UpdatedAt
+	wantJSON, _ := canonicalJSON(original)
+	gotJSON, _ := canonicalJSON(*after)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("touch changed more than updated_at:\n got %s\nwant %s", gotJSON, wantJSON)
+	}
+	if touched.Title != "x" || touched.Status != "in_progress" || strings.Join(touched.Tags, ",") != "api,ops" {
+		t.Errorf("touch response = %+v, want the task otherwise unchanged", touched)
+	}
+
+	before := created.CreatedAt.Add(-time.Hour).UTC().Format(time.RFC3339)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/admin/tasks/a/touch", `{"updated_at":"`+before+`"}`, auth...)
+	mustDo(t, h, http.StatusNotFound, http.MethodPost, "/admin/tasks/missing/touch", "", auth...)
+}
+
+func TestTagOrdering(t *testing.T) {
+	tests := []struct {
+		name     string
+		sortTags bool
+		want     string
+		wantPut  string
+	}{
+		{"sorted", true, "api,backend,urgent", "alpha,beta,zeta"},
+		{"stored order", false, "urgent,api,backend", "zeta,alpha,beta"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, h := newTestServer(t, func(cfg *Config) { cfg.SortTags = tt.sortTags })
+			created := createTasks(t, h, `[{"id":"a","title":"x","tags":["urgent","api","backend"]}]`)[0]
+
+			if got := strings.Join(created.Tags, ","); got != tt.want {
+				t.Errorf("tags = %s, want %s", got, tt.want)
+			}
+
+			stored, err := s.DB.GetTask("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(stored.Tags, ","); got != "urgent,api,backend" {
+				t.Errorf("stored tags = %s, want the submitted order", got)
+			}
+
+			var read Task
+			decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", ""), &read)
+			if got := strings.Join(read.Tags, ","); got != tt.want {
+				t.Errorf("read tags = %s, want %s", got, tt.want)
+			}
+
+			// The rewrite goes through dedupe, which must not reorder.
+			var updated Task
+			decodeBody(t, mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"tags":["zeta","alpha","Zeta","beta"]}`), &updated)
+			if got := strings.Join(updated.Tags, ","); got != tt.wantPut {
+				t.Errorf("tags after PUT = %s, want %s", got, tt.wantPut)
+			}
+			var reread Task
+			decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", ""), &reread)
+			if got := strings.Join(reread.Tags, ","); got != tt.wantPut {
+				t.Errorf("read tags after PUT = %s, want %s", got, tt.wantPut)
+			}
+		})
+	}
+}
+
+func TestReassignTasks(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[
+		{"id":"a","title":"x","assignee":"ann"},
+		{"id":"b","title":"y","assignee":"ann"},
+		{"id":"c","title":"z","assignee":"bob"}
+	]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/b", `{"status":"done"}`)
+
+	var result map[string]int
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/reassign", `{"from":"ann","to":"cid","status":"created"}`), &result)
+	if result["reassigned"] != 1 {
+		t.Errorf("reassigned = %d, want 1", result["reassigned"])
+	}
+
+	want := map[string]string{"a": "cid", "b": "ann", "c": "bob"}
+	for ID, assignee := range want {
+		task, err := s.DB.GetTask(ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.Assignee != assignee {
+			t.Errorf("task %s assignee = %q, want %q", ID, task.Assignee, assignee)
+		}
+	}
+
+	w := mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/reassign", `{"from":"cid","to":"dan"}`, "Accept", "application/msgpack")
+	var packed map[string]int
+	if err := msgpack.Unmarshal(w.Body.Bytes(), &packed); err != nil || packed["reassigned"] != 1 {
+		t.Errorf("msgpack reassign = %v, %v; want 1 reassigned", packed, err)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/reassign", `{"from":"ann"}`)
+}
+
+func TestUpsertByExternalID(t *testing.T) {
+	_, h := newTestServer(t, nil)
+
+	var first []Task
+	decodeBody(t, mustDo(t, h, http.StatusCreated, http.MethodPost, "/tasks?upsert_by=external_id", `[{"id":"a","title":"old","external_id":"EXT-1"}]`), &first)
+
+	var second []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks?upsert_by=external_id", `[{"id":"other","title":"new","external_id":"EXT-1","assignee":"ann"}]`), &second)
+	if len(second) != 1 {
+		t.Fatalf("got %d tasks, want 1", len(second))
+	}
+	updated := second[0]
+	if updated.ID != "a" || !updated.CreatedAt.Equal(first[0].CreatedAt) {
+		t.Errorf("upsert did not keep the stored id and created_at: %+v", updated)
+	}
+	if updated.Title != "new" || updated.Assignee != "ann" {
+		t.Errorf("upsert did not apply the new fields: %+v", updated)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks?upsert_by=external_id", `[{"id":"b","title":"no external id"}]`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks?upsert_by=title", `[]`)
+
+	var tasks []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks", ""), &tasks)
+	if len(tasks) != 1 {
+		t.Errorf("upserts left %d tasks, want 1", len(tasks))
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"plain", `{"id":"a"}`, false},
+		{"bom", "\xef\xbb\xbf{\"id\":\"a\"}", false},
+		{"trailing whitespace", "{\"id\":\"a\"}\n\t ", false},
+		{"second document", `{"id":"a"}{"id":"b"}`, true},
+		{"trailing garbage", `{"id":"a"} x`, true},
+		{"empty", ``, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v struct {
+				ID string `json:"id"`
+			}
+			err := decodeJSON(strings.NewReader(tt.body), &v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && v.ID != "a" {
+				t.Errorf("id = %q, want a", v.ID)
+			}
+		})
+	}
+}
+
+func TestCreateAcceptsBOM(t *testing.T) {
+	_, h := newTestServer(t, nil)
+
+	createTasks(t, h, "\xef\xbb\xbf[{\"id\":\"a\",\"title\":\"x\"}]\n")
+	mustDo(t, h, http.StatusBadRequest, http.MethodPut, "/tasks/a", `{"title":"y"} {"title":"z"}`)
+}
+
+func taskIDs(tasks []Task) string {
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestPagination(t *testing.T) {
+	_, h := newTestServer(t, func(cfg *Config) { cfg.MaxOffset = 3 })
+	createTasks(t, h, `[{"id":"a","title":"1"},{"id":"b","title":"2"},{"id":"c","title":"3"},{"id":"d","title":"4"},{"id":"e","title":"5"}]`)
+
+	var page []Task
+	w := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?sort=id&limit=2&offset=1", "")
+	decodeBody(t, w, &page)
+	if got := taskIDs(page); got != "b,c" {
+		t.Errorf("offset page = %s, want b,c", got)
+	}
+	if got := w.Header().Get("X-Total-Count"); got != "5" {
+		t.Errorf("X-Total-Count = %q, want 5", got)
+	}
+
+	var walked []string
+	target := "/tasks?sort=id&limit=2"
+	for target != "" {
+		w := mustDo(t, h, http.StatusOK, http.MethodGet, target, "")
+		decodeBody(t, w, &page)
+		walked = append(walked, taskIDs(page))
+		target = ""
+		if cursor := w.Header().Get("X-Next-Cursor"); cursor != "" {
+			target = "/tasks?sort=id&limit=2&after=" + cursor
+		}
+	}
+	if got := strings.Join(walked, "|"); got != "a,b|c,d|e" {
+		t.Errorf("cursor walk = %s, want a,b|c,d|e", got)
+	}
+
+	w = mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?offset=4", "")
+	if !strings.Contains(w.Body.String(), "after") {
+		t.Errorf("deep offset error does not point at cursors: %s", w.Body.String())
+	}
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?after=garbage", "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?limit=-1", "")
+}
+
+func TestEmptyIsError(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","assignee":"ann"}]`)
+
+	w := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?assignee=bob", "")
+	if got := strings.TrimSpace(w.Body.String()); got != "[]" {
+		t.Errorf("empty filter body = %s, want []", got)
+	}
+
+	mustDo(t, h, http.StatusNotFound, http.MethodGet, "/tasks?assignee=bob&empty_is_error=true", "")
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?assignee=ann&empty_is_error=true", "")
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?assignee=ann&empty_is_error=true&offset=5", "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?empty_is_error=sometimes", "")
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	_, h := newTestServer(t, nil)
+
+	var body bytes.Buffer
+	if err := (MsgpackCodec{}).Encode(&body, []map[string]interface{}{{"id": "a", "title": "packed", "tags": []string{"x"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks", body.String(), "Content-Type", "application/msgpack", "Accept", "application/msgpack")
+	if got := w.Header().Get("Content-Type"); got != "application/msgpack" {
+		t.Errorf("Content-Type = %q, want application/msgpack", got)
+	}
+
+	var created []map[string]interface{}
+	if err := msgpack.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("response is not msgpack: %v", err)
+	}
+	if len(created) != 1 || created[0]["id"] != "a" || created[0]["title"] != "packed" {
+		t.Errorf("created = %v", created)
+	}
+
+	var task Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", ""), &task)
+	if task.Title != "packed" || len(task.Tags) != 1 {
+		t.Errorf("JSON read of a msgpack write = %+v", task)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks", "not msgpack", "Content-Type", "application/msgpack")
+}
+
+func TestReadyzDegradesOnLockWait(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) { cfg.ReadyLockWaitThresholdMS = 50 })
+
+	var status ReadinessStatus
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/readyz", ""), &status)
+	if status.Status != "ready" {
+		t.Errorf("status = %q, want ready", status.Status)
+	}
+
+	db := s.DB.(*MapDB)
+	for range 10 {
+		db.lockWaits.record(200 * time.Millisecond)
+	}
+	decodeBody(t, mustDo(t, h, http.StatusServiceUnavailable, http.MethodGet, "/readyz", ""), &status)
+	if status.Status != "degraded" || status.LockWaitLimitMS != 50 {
+		t.Errorf("status = %+v, want degraded with limit 50", status)
+	}
+
+	s.Config.ReadyLockWaitThresholdMS = 0
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/readyz", "")
+}
+
+func TestLockWaitAverageWindow(t *testing.T) {
+	var stats lockWaitStats
+	if got := stats.average(time.Second); got != 0 {
+		t.Errorf("empty average = %v, want 0", got)
+	}
+
+	stats.record(10 * time.Millisecond)
+	stats.record(30 * time.Millisecond)
+	if got := stats.average(time.Minute); got != 20*time.Millisecond {
+		t.Errorf("average = %v, want 20ms", got)
+	}
+
+	for range lockWaitMaxSamples {
+		stats.record(time.Millisecond)
+	}
+	if len(stats.samples) != lockWaitMaxSamples {
+		t.Errorf("ring buffer holds %d samples, want %d", len(stats.samples), lockWaitMaxSamples)
+	}
+	if got := stats.average(time.Minute); got != time.Millisecond {
+		t.Errorf("average after wrap = %v, want 1ms", got)
+	}
+}
+
+func TestWorkload(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	createTasks(t, h, `[
+		{"id":"a","title":"1","assignee":"ann","due_at":"`+past+`","estimate_minutes":30,"tags":["ops"]},
+		{"id":"b","title":"2","assignee":"ann","estimate_minutes":15},
+		{"id":"c","title":"3","assignee":"ann"},
+		{"id":"d","title":"4","assignee":"bob","tags":["ops"]},
+		{"id":"e","title":"5"}
+	]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/b", `{"status":"in_progress"}`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/c", `{"status":"done"}`)
+
+	var workload []AssigneeWorkload
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/workload", ""), &workload)
+
+	want := []AssigneeWorkload{
+		{Assignee: "ann", Open: 2, InProgress: 1, Overdue: 1, EstimateMinutes: 45},
+		{Assignee: "bob", Open: 1},
+	}
+	if len(workload) != len(want) {
+		t.Fatalf("workload = %+v, want %+v", workload, want)
+	}
+	for i := range want {
+		if workload[i] != want[i] {
+			t.Errorf("workload[%d] = %+v, want %+v", i, workload[i], want[i])
+		}
+	}
+
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/workload?tag=ops", ""), &workload)
+	if len(workload) != 2 || workload[0].Open != 1 || workload[1].Open != 1 {
+		t.Errorf("tag-filtered workload = %+v, want one open task each", workload)
+	}
+
+	// Overdue follows the server clock: two hours ago, a was not due yet.
+	s.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	var earlier []AssigneeWorkload
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/workload", ""), &earlier)
+	if len(earlier) == 0 || earlier[0].Overdue != 0 {
+		t.Errorf("workload under an earlier clock = %+v, want nothing overdue", earlier)
+	}
+}
+
+func TestTimezoneFiltersAndDisplay(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","due_at":"2026-03-01T23:30:00Z"}]`)
+
+	count := func(target string) int {
+		var tasks []Task
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, target, ""), &tasks)
+		return len(tasks)
+	}
+	if got := count("/tasks?due_on=2026-03-01"); got != 1 {
+		t.Errorf("UTC day match = %d, want 1", got)
+	}
+	if got := count("/tasks?due_on=2026-03-01&tz=Asia/Tokyo"); got != 0 {
+		t.Errorf("Tokyo previous day match = %d, want 0", got)
+	}
+	if got := count("/tasks?due_on=2026-03-02&tz=Asia/Tokyo"); got != 1 {
+		t.Errorf("Tokyo local day match = %d, want 1", got)
+	}
+
+	w := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a?tz=Asia/Tokyo", "")
+	if !strings.Contains(w.Body.String(), `"due_at":"2026-03-02T08:30:00+09:00"`) {
+		t.Errorf("due_at not rendered in Tokyo time: %s", w.Body.String())
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?tz=Mars/Olympus", "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?due_on=03/01/2026", "")
+}
+
+func TestEstimatesAndSpentTime(t *testing.T) {
+	_, h := newTestServer(t, nil)
+
+	created := createTasks(t, h, `[{"id":"a","title":"x","estimate_minutes":60,"spent_minutes":20}]`)[0]
+	if created.RemainingMinutes != 40 {
+		t.Errorf("remaining = %d, want 40", created.RemainingMinutes)
+	}
+
+	var task Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/a/spent", `{"minutes":50}`), &task)
+	if task.SpentMinutes != 70 || task.RemainingMinutes != 0 {
+		t.Errorf("after logging time: spent %d remaining %d, want 70 and 0", task.SpentMinutes, task.RemainingMinutes)
+	}
+
+	decodeBody(t, mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"estimate_minutes":100}`), &task)
+	if task.RemainingMinutes != 30 {
+		t.Errorf("remaining after re-estimate = %d, want 30", task.RemainingMinutes)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks", `[{"id":"b","title":"y","estimate_minutes":-1}]`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPut, "/tasks/a", `{"spent_minutes":-5}`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPut, "/tasks/a", `{"estimate_minutes":1.5}`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/a/spent", `{"minutes":0}`)
+	mustDo(t, h, http.StatusNotFound, http.MethodPost, "/tasks/missing/spent", `{"minutes":5}`)
+}
+
+func TestDefaultSort(t *testing.T) {
+	list := func(h http.Handler, target string) string {
+		var tasks []Task
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, target, ""), &tasks)
+		return taskIDs(tasks)
+	}
+
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"b","priority":1},{"id":"b","title":"c","priority":1},{"id":"c","title":"a","priority":2}]`)
+
+	if got := list(h, "/tasks"); got != "c,b,a" {
+		t.Errorf("default order = %s, want newest first", got)
+	}
+	for target, want := range map[string]string{
+		"/tasks?sort=title":           "c,a,b",
+		"/tasks?sort=-title":          "b,a,c",
+		"/tasks?sort=priority%20desc": "c,b,a",
+		"/tasks?sort=priority":        "a,b,c",
+	} {
+		if got := list(h, target); got != want {
+			t.Errorf("%s = %s, want %s", target, got, want)
+		}
+	}
+
+	_, titled := newTestServer(t, func(cfg *Config) { cfg.DefaultSort = "title" })
+	createTasks(t, titled, `[{"id":"a","title":"b"},{"id":"b","title":"a"}]`)
+	if got := list(titled, "/tasks"); got != "b,a" {
+		t.Errorf("configured default order = %s, want b,a", got)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?sort=color", "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?sort=title%20sideways", "")
+}
+
+func TestChangesSinceSeq(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x"}]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"y"}`)
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/a", "")
+
+	var feed ChangesResponse
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/changes", ""), &feed)
+	if len(feed.Changes) != 3 || feed.LastSeq != 3 {
+		t.Fatalf("feed = %+v, want 3 changes up to seq 3", feed)
+	}
+	for i, want := range []string{ChangeCreated, ChangeUpdated, ChangeArchived} {
+		if change := feed.Changes[i]; change.Type != want || change.Seq != uint64(i+1) || change.Task.ID != "a" {
+			t.Errorf("change %d = %s seq %d, want %s seq %d", i, change.Type, change.Seq, want, i+1)
+		}
+	}
+
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/changes?since_seq=2", ""), &feed)
+	if len(feed.Changes) != 1 || feed.Changes[0].Type != ChangeArchived {
+		t.Errorf("since_seq=2 = %+v, want only the archive", feed.Changes)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/changes?since_seq=-1", "")
+
+	tasks := make([]Task, maxRetainedChanges)
+	for i := range tasks {
+		tasks[i] = Task{ID: "bulk" + strconv.Itoa(i), Title: "x"}
+	}
+	if _, err := s.DB.AddTasks(tasks); err != nil {
+		t.Fatal(err)
+	}
+	mustDo(t, h, http.StatusGone, http.MethodGet, "/tasks/changes?since_seq=1", "")
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/changes?since_seq=3", "")
+}
+
+func TestChangesSeqSurvivesRestart(t *testing.T) {
+	path := t.TempDir() + "/seq"
+
+	s, h := newTestServer(t, nil)
+	db := NewMapDB()
+	if err := db.PersistChangeSeq(path); err != nil {
+		t.Fatal(err)
+	}
+	s.DB = db
+
+	// More changes than one reservation block, so the mark is moved on.
+	tasks := make([]Task, seqReservation+500)
+	for i := range tasks {
+		tasks[i] = Task{ID: "bulk" + strconv.Itoa(i), Title: "x"}
+	}
+	if _, err := db.AddTasks(tasks); err != nil {
+		t.Fatal(err)
+	}
+	var before ChangesResponse
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/changes?since_seq=1490", ""), &before)
+	if before.LastSeq != uint64(len(tasks)) {
+		t.Fatalf("last_seq = %d, want %d", before.LastSeq, len(tasks))
+	}
+
+	restarted := NewMapDB()
+	if err := restarted.PersistChangeSeq(path); err != nil {
+		t.Fatal(err)
+	}
+	s.DB = restarted
+	createTasks(t, h, `[{"id":"a","title":"x"}]`)
+
+	var after ChangesResponse
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/changes", ""), &after)
+	if len(after.Changes) != 1 || after.Changes[0].Seq <= before.LastSeq {
+		t.Fatalf("after a restart = %+v, want one change numbered above %d", after, before.LastSeq)
+	}
+
+	// A client still holding a sequence number from the earlier run must
+	// resync instead of silently missing changes.
+	mustDo(t, h, http.StatusGone, http.MethodGet, "/tasks/changes?since_seq="+strconv.FormatUint(before.LastSeq, 10), "")
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/changes?since_seq="+strconv.FormatUint(after.LastSeq, 10), "")
+
+	if history, complete, _ := restarted.GetTaskHistory("a"); len(history) != 1 || !complete {
+		t.Errorf("history = %+v complete %v, want the one create and complete", history, complete)
+	}
+}
+
+// TestArchiveUpdateRace is meant for go test -race: updates racing an
+// archive either land before it or get 409, and never revive the task.
+func TestArchiveUpdateRace(t *testing.T) {
+	s, h := newTestServer(t, nil)
+
+	for i := range 20 {
+		ID := "race" + strconv.Itoa(i)
+		createTasks(t, h, `[{"id":"`+ID+`","title":"x"}]`)
+
+		var wg sync.WaitGroup
+		codes := make(chan int, 8)
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes <- do(t, h, http.MethodPut, "/tasks/"+ID, `{"status":"in_progress"}`).Code
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code := do(t, h, http.MethodDelete, "/tasks/"+ID, "").Code; code != http.StatusNoContent {
+				t.Errorf("delete %s: status %d", ID, code)
+			}
+		}()
+		wg.Wait()
+		close(codes)
+
+		for code := range codes {
+			if code != http.StatusCreated && code != http.StatusConflict {
+				t.Errorf("update %s: status %d, want 201 or 409", ID, code)
+			}
+		}
+
+		task, err := s.DB.GetTask(ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.ArchivedAt == nil || task.Status != "archived" {
+			t.Errorf("task %s was revived: status %q archived_at %v", ID, task.Status, task.ArchivedAt)
+		}
+		mustDo(t, h, http.StatusConflict, http.MethodPut, "/tasks/"+ID, `{"title":"late"}`)
+	}
+}
+
+func TestICalFeed(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[
+		{"id":"a","title":"Ship, then celebrate; really","assignee":"ann","due_at":"2026-03-01T12:00:00Z"},
+		{"id":"b","title":"`+strings.Repeat("long ", 30)+`","assignee":"ann","due_at":"2026-03-02T12:00:00Z"},
+		{"id":"c","title":"no due date","assignee":"ann"},
+		{"id":"d","title":"someone else","assignee":"bob","due_at":"2026-03-03T12:00:00Z"}
+	]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"status":"done"}`)
+
+	w := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks.ics?assignee=ann", "")
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/calendar") {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	body := w.Body.String()
+	if n := strings.Count(body, "BEGIN:VTODO"); n != 2 {
+		t.Errorf("got %d VTODOs, want 2", n)
+	}
+	for _, want := range []string{
+		`SUMMARY:Ship\, then celebrate\; really` + "\r\n",
+		"DUE:20260301T120000Z\r\n",
+		"STATUS:COMPLETED\r\n",
+		"STATUS:NEEDS-ACTION\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("feed is missing %q", want)
+		}
+	}
+	for _, line := range strings.Split(body, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets is not folded: %q", len(line), line)
+		}
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	created := createTasks(t, h, `[{"id":"a","title":"x","assignee":"ann"},{"id":"b","title":"y"}]`)
+
+	var checksums map[string]string
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/checksums", ""), &checksums)
+	if len(checksums) != 2 || checksums["a"] != created[0].Checksum || checksums["a"] == "" {
+		t.Fatalf("checksums = %v, want the ones returned on create", checksums)
+	}
+
+	var inTokyo Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a?tz=Asia/Tokyo", ""), &inTokyo)
+	if inTokyo.Checksum != checksums["a"] {
+		t.Errorf("checksum depends on tz: %s vs %s", inTokyo.Checksum, checksums["a"])
+	}
+
+	var updated Task
+	decodeBody(t, mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"changed"}`), &updated)
+	if updated.Checksum == checksums["a"] {
+		t.Error("checksum did not change with the task")
+	}
+
+	var filtered map[string]string
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/checksums?assignee=ann", ""), &filtered)
+	if len(filtered) != 1 || filtered["a"] != updated.Checksum {
+		t.Errorf("filtered checksums = %v, want only a at %s", filtered, updated.Checksum)
+	}
+}
+
+func TestTimeFormats(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		accept string
+		want   func(time.Time) interface{}
+	}{
+		{"default", TimeFormatRFC3339, "", func(at time.Time) interface{} { return at.Format(time.RFC3339Nano) }},
+		{"config unix", TimeFormatUnix, "", func(at time.Time) interface{} { return float64(at.Unix()) }},
+		{"accept unix_ms", TimeFormatRFC3339, "application/json; time-format=unix_ms", func(at time.Time) interface{} { return float64(at.UnixMilli()) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, h := newTestServer(t, func(cfg *Config) { cfg.TimeFormat = tc.config })
+			mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks", `[{"id":"a","title":"x","due_at":"2026-03-01T12:00:00Z"}]`)
+			stored, err := s.DB.GetTask("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]interface{}
+			decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", "", "Accept", tc.accept), &got)
+			for field, at := range map[string]time.Time{
+				"created_at": stored.CreatedAt,
+				"updated_at": stored.UpdatedAt,
+				"due_at":     *stored.DueAt,
+			} {
+				if want := tc.want(at); got[field] != want {
+					t.Errorf("%s = %v (%T), want %v", field, got[field], got[field], want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportDeduplicatesByExternalID(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	ndjson := []string{"Content-Type", "application/x-ndjson"}
+	file := `{"id":"a","title":"one","external_id":"ext-1"}` + "\n" + `{"id":"b","title":"two","external_id":"ext-2"}` + "\n"
+	// A re-export from elsewhere carries fresh IDs; only external_id ties
+	// its rows to the stored ones.
+	rerun := strings.NewReplacer(`"id":"a"`, `"id":"a2"`, `"id":"b"`, `"id":"b2"`).Replace(file)
+
+	for _, step := range []struct {
+		target string
+		body   string
+		want   ImportResult
+	}{
+		{"/tasks/import", file, ImportResult{Created: 2}},
+		{"/tasks/import", rerun, ImportResult{Skipped: 2}},
+		{"/tasks/import?on_existing=update", strings.Replace(rerun, "one", "uno", 1), ImportResult{Updated: 2}},
+	} {
+		var got ImportResult
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, step.target, step.body, ndjson...), &got)
+		if got != step.want {
+			t.Errorf("%s: result = %+v, want %+v", step.target, got, step.want)
+		}
+	}
+
+	tasks, err := s.DB.GetTasks(TaskFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("got %d tasks after re-imports, want 2", len(tasks))
+	}
+	task, err := s.DB.GetTaskByExternalID("ext-1")
+	if err != nil || task.Title != "uno" {
+		t.Errorf("ext-1 = %+v, %v; want it updated to uno", task, err)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/import?on_existing=replace", file, ndjson...)
+}
+
+func TestForcedErrors(t *testing.T) {
+	for _, debug := range []bool{true, false} {
+		_, h := newTestServer(t, func(cfg *Config) {
+			cfg.Debug = debug
+			cfg.ForcedErrors = map[string]int{"fail-500": http.StatusInternalServerError}
+		})
+		createTasks(t, h, `[{"id":"fail-500","title":"x"},{"id":"ok","title":"y"}]`)
+
+		want := http.StatusOK
+		if debug {
+			want = http.StatusInternalServerError
+		}
+		mustDo(t, h, want, http.MethodGet, "/tasks/fail-500", "")
+		mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/ok", "")
+	}
+}
+
+func TestForcedErrorsRejectsNonErrorStatus(t *testing.T) {
+	path := t.TempDir() + "/config.json"
+	if err := os.WriteFile(path, []byte(`{"forced_errors":{"a":204}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "forced_errors") {
+		t.Errorf("LoadConfig = %v, want a forced_errors error", err)
+	}
+}
+
+// recordingNotifier hands every event it is notified of to a channel.
+type recordingNotifier chan TaskEvent
+
+func (n recordingNotifier) Notify(ctx context.Context, event TaskEvent) error {
+	n <- event
+	return nil
+}
+
+func TestNotifierReceivesMutations(t *testing.T) {
+	s, h := newTestServer(t, nil)
+
+	events := make(recordingNotifier, 10)
+	hub := NewNotifierHub(10, 2, time.Second, NopNotifier{}, LogNotifier{Logger: log.New(io.Discard, "", 0)}, events)
+	s.DB.(ChangeSubscriber).OnChange(hub.publishChange)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+
+	createTasks(t, h, `[{"id":"a","title":"x"}]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"y"}`)
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/a", "")
+	cancel()
+	<-done
+
+	for _, want := range []string{ChangeCreated, ChangeUpdated, ChangeArchived} {
+		select {
+		case event := <-events:
+			if event.Type != want || event.Task.ID != "a" {
+				t.Errorf("event = %s %q, want %s a", event.Type, event.Task.ID, want)
+			}
+			if want == ChangeUpdated && string(event.Diff["title"].New) != `"y"` {
+				t.Errorf("update diff = %+v, want the new title", event.Diff)
+			}
+		default:
+			t.Fatalf("no %s event delivered", want)
+		}
+	}
+}
+
+// compactingDB stands in for an on-disk backend.
+type compactingDB struct {
+	*MapDB
+	reclaimed int64
+}
+
+func (db compactingDB) Compact() (int64, error) { return db.reclaimed, nil }
+
+func TestAdminCompact(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) { cfg.AdminToken = "secret" })
+	auth := []string{"Authorization", "Bearer secret"}
+	createTasks(t, h, `[{"id":"a","title":"x"}]`)
+
+	mustDo(t, h, http.StatusUnauthorized, http.MethodPost, "/admin/compact", "")
+	mustDo(t, h, http.StatusMethodNotAllowed, http.MethodGet, "/admin/compact", "", auth...)
+
+	var result CompactResult
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/admin/compact", "", auth...), &result)
+	if result != (CompactResult{}) {
+		t.Errorf("MapDB compaction = %+v, want a no-op", result)
+	}
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", "")
+
+	s.DB = compactingDB{MapDB: s.DB.(*MapDB), reclaimed: 4096}
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/admin/compact", "", auth...), &result)
+	if want := (CompactResult{Supported: true, ReclaimedBytes: 4096}); result != want {
+		t.Errorf("compaction = %+v, want %+v", result, want)
+	}
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", "")
+}
+
+func TestStrictQuery(t *testing.T) {
+	_, lenient := newTestServer(t, nil)
+	createTasks(t, lenient, `[{"id":"a","title":"x"}]`)
+	var tasks []Task
+	decodeBody(t, mustDo(t, lenient, http.StatusOK, http.MethodGet, "/tasks?statuz=done", ""), &tasks)
+	if len(tasks) != 1 {
+		t.Errorf("lenient mode returned %d tasks, want the typo ignored", len(tasks))
+	}
+
+	_, strict := newTestServer(t, func(cfg *Config) { cfg.StrictQuery = true })
+	w := mustDo(t, strict, http.StatusBadRequest, http.MethodGet, "/tasks?statuz=done&limitt=1&status=done", "")
+	if got := w.Body.String(); !strings.Contains(got, "limitt, statuz") || strings.Contains(got, "status,") {
+		t.Errorf("error = %q, want only the unknown parameters listed", got)
+	}
+	mustDo(t, strict, http.StatusOK, http.MethodGet, "/tasks?status=done&limit=1&tz=UTC", "")
+	mustDo(t, strict, http.StatusBadRequest, http.MethodGet, "/tasks/export?statuz=done", "")
+}
+
+func TestListDuringWrites(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","tags":["one"]},{"id":"b","title":"y"}]`)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			n := strconv.Itoa(i)
+			do(t, h, http.MethodPut, "/tasks/a", `{"title":"x`+n+`","tags":["one","t`+n+`"]}`)
+			do(t, h, http.MethodPost, "/tasks", `[{"id":"new-`+strconv.Itoa(i%20)+`","title":"z"}]`)
+		}
+	}()
+
+	for range 50 {
+		var tasks []Task
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?limit=1000", ""), &tasks)
+		if len(tasks) < 2 {
+			t.Fatalf("listed %d tasks, want at least 2", len(tasks))
+		}
+		if _, err := s.DB.GetTasks(TaskFilter{Tag: "one"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func BenchmarkGetTasksDuringWrites(b *testing.B) {
+	s, h := newTestServer(b, nil)
+	var body strings.Builder
+	body.WriteString("[")
+	for i := range 1000 {
+		if i > 0 {
+			body.WriteString(",")
+		}
+		body.WriteString(`{"id":"t` + strconv.Itoa(i) + `","title":"x"}`)
+	}
+	body.WriteString("]")
+	mustDo(b, h, http.StatusOK, http.MethodPost, "/tasks", body.String())
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			s.DB.UpdateTask(map[string]interface{}{"title": strconv.Itoa(i)}, "t"+strconv.Itoa(i%1000))
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := s.DB.GetTasks(TaskFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	close(stop)
+	<-done
+}
+
+func TestChangeDiffNamesChangedFields(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","assignee":"ann"}]`)
+
+	var mx sync.Mutex
+	var changes []Change
+	s.DB.(ChangeSubscriber).OnChange(func(change Change) {
+		mx.Lock()
+		defer mx.Unlock()
+		changes = append(changes, change)
+	})
+
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"status":"blocked"}`)
+
+	mx.Lock()
+	defer mx.Unlock()
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(changes))
+	}
+	diff := changes[0].Diff
+	if len(diff) != 1 || string(diff["status"].Old) != `"created"` || string(diff["status"].New) != `"blocked"` {
+		t.Errorf("diff = %v, want exactly status created -> blocked", diff)
+	}
+}
+
+func TestConcurrentImportLimit(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) { cfg.MaxConcurrentImports = 1 })
+	body := `[{"id":"a","title":"x"}]`
+
+	// Hold the only slot as a long-running import would.
+	s.imports <- struct{}{}
+	w := mustDo(t, h, http.StatusTooManyRequests, http.MethodPost, "/tasks/import", body)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 without Retry-After")
+	}
+
+	<-s.imports
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/import", body)
+	if len(s.imports) != 0 {
+		t.Error("import did not release its slot")
+	}
+}
+
+func TestIDOnly(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","assignee":"ann"},{"id":"b","title":"y"},{"id":"c","title":"z","assignee":"ann"}]`)
+
+	var ids []string
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?id_only=true&assignee=ann&sort=id", ""), &ids)
+	if strings.Join(ids, ",") != "a,c" {
+		t.Errorf("ids = %v, want [a c]", ids)
+	}
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?id_only=maybe", "")
+}
+
+func TestNextTask(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[
+		{"id":"urgent-but-blocked","title":"x","assignee":"ann","priority":9},
+		{"id":"someone-else","title":"x","assignee":"bob","priority":8},
+		{"id":"high-later","title":"x","assignee":"ann","priority":5,"due_at":"2026-03-09T00:00:00Z"},
+		{"id":"high-sooner","title":"x","assignee":"ann","priority":5,"due_at":"2026-03-02T00:00:00Z"},
+		{"id":"high-undated","title":"x","assignee":"ann","priority":5},
+		{"id":"low","title":"x","assignee":"ann","priority":1,"due_at":"2026-03-01T00:00:00Z"}
+	]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/urgent-but-blocked", `{"status":"blocked"}`)
+
+	for _, want := range []string{"high-sooner", "high-later", "high-undated", "low"} {
+		var next Task
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/next?assignee=ann", ""), &next)
+		if next.ID != want {
+			t.Fatalf("next = %s, want %s", next.ID, want)
+		}
+		mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/"+want, `{"status":"done"}`)
+	}
+
+	mustDo(t, h, http.StatusNotFound, http.MethodGet, "/tasks/next?assignee=ann", "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/next", "")
+}
+
+func TestHeadTasksCounts(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x"},{"id":"b","title":"y"},{"id":"c","title":"z"}]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/b", `{"status":"blocked"}`)
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/c", "")
+	archived, err := s.DB.GetTask("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := mustDo(t, h, http.StatusOK, http.MethodHead, "/tasks", "")
+	for header, want := range map[string]string{
+		"X-Total-Count":    "3",
+		"X-Count-Created":  "1",
+		"X-Count-Archived": "1",
+		"Last-Modified":    archived.ArchivedAt.UTC().Format(http.TimeFormat),
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("HEAD wrote a body: %q", w.Body.String())
+	}
+
+	w = mustDo(t, h, http.StatusOK, http.MethodHead, "/tasks?status=blocked", "")
+	if got := w.Header().Get("X-Total-Count"); got != "1" {
+		t.Errorf("filtered X-Total-Count = %q, want 1", got)
+	}
+}
+
+func TestMergeTasks(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) { cfg.TagLimit = SoftLimit{Soft: 2, Hard: 3} })
+	createTasks(t, h, `[
+		{"id":"dup","title":"x","tags":["a","b"]},
+		{"id":"main","title":"x","tags":["b","c"]},
+		{"id":"big","title":"y","tags":["c","d","e"]}
+	]`)
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/dup/merge", `{"into":"dup"}`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/dup/merge", `{}`)
+	mustDo(t, h, http.StatusNotFound, http.MethodPost, "/tasks/dup/merge", `{"into":"missing"}`)
+
+	var merged Task
+	w := mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/dup/merge", `{"into":"main"}`)
+	decodeBody(t, w, &merged)
+	if merged.ID != "main" || strings.Join(merged.Tags, ",") != "a,b,c" {
+		t.Errorf("merged = %s %v, want main with tags [a b c]", merged.ID, merged.Tags)
+	}
+	if !strings.Contains(w.Header().Get("Warning"), "soft limit") {
+		t.Errorf("Warning = %q, want the soft tag limit reported", w.Header().Get("Warning"))
+	}
+
+	source, err := s.DB.GetTask("dup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.ArchivedAt == nil || source.MergedInto != "main" {
+		t.Errorf("source = %+v, want it archived and merged into main", source)
+	}
+	mustDo(t, h, http.StatusConflict, http.MethodPost, "/tasks/dup/merge", `{"into":"main"}`)
+
+	mustDo(t, h, http.StatusUnprocessableEntity, http.MethodPost, "/tasks/big/merge", `{"into":"main"}`)
+	if big, _ := s.DB.GetTask("big"); big.ArchivedAt != nil {
+		t.Error("a merge over the tag limit archived its source")
+	}
+}
+
+func TestBatchUpdateVersions(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	created := createTasks(t, h, `[{"id":"a","title":"x"},{"id":"b","title":"y"}]`)
+	stale := created[0].Checksum
+	var current Task
+	decodeBody(t, mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"x2"}`), &current)
+
+	batch := `[
+		{"id":"a","checksum":"` + stale + `","data":{"title":"lost"}},
+		{"id":"b","checksum":"` + created[1].Checksum + `","data":{"title":"y2"}}
+	]`
+
+	var results []BatchUpdateResult
+	decodeBody(t, mustDo(t, h, http.StatusConflict, http.MethodPost, "/tasks/batch-update?atomic=true", batch), &results)
+	if len(results) != 2 || results[0].Status != http.StatusConflict || results[1].Status != http.StatusFailedDependency {
+		t.Fatalf("atomic results = %+v, want 409 then 424", results)
+	}
+	if b, _ := s.DB.GetTask("b"); b.Title != "y" {
+		t.Errorf("atomic batch applied b anyway: %q", b.Title)
+	}
+
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/batch-update", batch), &results)
+	if len(results) != 2 || results[0].Status != http.StatusConflict || results[1].Status != http.StatusOK {
+		t.Fatalf("results = %+v, want 409 then 200", results)
+	}
+	if results[1].Task == nil || results[1].Task.Title != "y2" {
+		t.Errorf("b result = %+v, want the updated task", results[1].Task)
+	}
+	if a, _ := s.DB.GetTask("a"); a.Title != current.Title {
+		t.Errorf("stale update overwrote a: %q", a.Title)
+	}
+
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/batch-update", `[{"id":"a","data":{"title":"x3"}}]`), &results)
+	if results[0].Status != http.StatusOK {
+		t.Errorf("unversioned item = %+v, want 200", results[0])
+	}
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x"}]`)
+	s.readOnly.Store(true)
+
+	for _, write := range []struct{ method, target, body string }{
+		{http.MethodPost, "/tasks", `[{"id":"b","title":"y"}]`},
+		{http.MethodPut, "/tasks/a", `{"title":"y"}`},
+		{http.MethodDelete, "/tasks/a", ""},
+		{http.MethodPost, "/tasks/import", `[{"id":"b","title":"y"}]`},
+	} {
+		if w := do(t, h, write.method, write.target, write.body); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "read-only") {
+			t.Errorf("%s %s = %d %q, want 503 read-only", write.method, write.target, w.Code, w.Body.String())
+		}
+	}
+
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", "")
+	mustDo(t, h, http.StatusOK, http.MethodHead, "/tasks", "")
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/validate-batch", `[{"id":"b","title":"y"}]`)
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/a/patch/preview", `[{"op":"replace","path":"/title","value":"y"}]`)
+	if a, _ := s.DB.GetTask("a"); a.Title != "x" || a.ArchivedAt != nil {
+		t.Errorf("task changed in read-only mode: %+v", a)
+	}
+
+	s.readOnly.Store(false)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"y"}`)
+}
+
+func TestTaskDiff(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x"}]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"y"}`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"assignee":"ann"}`)
+
+	history, _, err := s.DB.GetTaskHistory("a")
+	if err != nil || len(history) != 3 {
+		t.Fatalf("history = %d changes, %v; want 3", len(history), err)
+	}
+	at := func(i int) string { return history[i].At.Format(time.RFC3339Nano) }
+
+	changedFields := func(target string) (TaskDiff, string) {
+		t.Helper()
+		var diff TaskDiff
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, target, ""), &diff)
+		fields := make([]string, 0, len(diff.Diff))
+		for field := range diff.Diff {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		return diff, strings.Join(fields, ",")
+	}
+
+	if _, fields := changedFields("/tasks/a/diff?from=" + at(0) + "&to=" + at(2)); fields != "assignee,title" {
+		t.Errorf("diff across both updates names %s, want assignee,title", fields)
+	}
+	if diff, fields := changedFields("/tasks/a/diff?from=" + at(1)); fields != "assignee" || !diff.FromKnown {
+		t.Errorf("diff since the first update names %s (from_known %v), want assignee", fields, diff.FromKnown)
+	}
+
+	before := history[0].At.Add(-time.Second).Format(time.RFC3339Nano)
+	diff, _ := changedFields("/tasks/a/diff?from=" + before)
+	if diff.FromKnown || string(diff.Diff["title"].Old) != "null" || string(diff.Diff["title"].New) != `"y"` {
+		t.Errorf("diff from before creation = %+v, want unknown old state", diff)
+	}
+
+	mustDo(t, h, http.StatusNotFound, http.MethodGet, "/tasks/a/diff?from="+before+"&to="+before, "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/a/diff?from=yesterday", "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/a/diff?from="+at(2)+"&to="+at(0), "")
+}
+
+func TestDeleteMissingOK(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	mustDo(t, h, http.StatusNotFound, http.MethodDelete, "/tasks/missing", "")
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/missing?missing_ok=true", "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodDelete, "/tasks/missing?missing_ok=perhaps", "")
+
+	_, lenient := newTestServer(t, func(cfg *Config) { cfg.DeleteMissingOK = true })
+	mustDo(t, lenient, http.StatusNoContent, http.MethodDelete, "/tasks/missing", "")
+	mustDo(t, lenient, http.StatusNotFound, http.MethodDelete, "/tasks/missing?missing_ok=false", "")
+	mustDo(t, lenient, http.StatusNotFound, http.MethodGet, "/tasks/missing", "")
+}
+
+func TestChangesPaging(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	for i := range 10 {
+		createTasks(t, h, `[{"id":"t`+strconv.Itoa(i)+`","title":"x"}]`)
+	}
+
+	var seen []uint64
+	pages := 0
+	for since := uint64(0); ; {
+		pages++
+		var page ChangesResponse
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/changes?limit=3&since_seq="+strconv.FormatUint(since, 10), ""), &page)
+		if page.LastSeq != 10 || len(page.Changes) > 3 {
+			t.Fatalf("page = %+v, want at most 3 changes of 10", page)
+		}
+		for _, change := range page.Changes {
+			seen = append(seen, change.Seq)
+		}
+		since = page.NextSeq
+		if !page.HasMore {
+			break
+		}
+	}
+
+	if pages != 4 || len(seen) != 10 {
+		t.Fatalf("drained %d changes in %d pages, want 10 in 4", len(seen), pages)
+	}
+	for i, seq := range seen {
+		if seq != uint64(i+1) {
+			t.Fatalf("sequence %v skips or repeats at %d", seen, i)
+		}
+	}
+
+	var empty ChangesResponse
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/changes?limit=3&since_seq=10", ""), &empty)
+	if len(empty.Changes) != 0 || empty.NextSeq != 10 || empty.HasMore {
+		t.Errorf("caught-up page = %+v, want it empty at seq 10", empty)
+	}
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/changes?limit=0", "")
+}
+
+func TestAssigneeRegistry(t *testing.T) {
+	_, h := newTestServer(t, func(cfg *Config) { cfg.Assignees = []string{"ann", "bob"} })
+	createTasks(t, h, `[{"id":"a","title":"x","assignee":"ann"},{"id":"b","title":"y"}]`)
+
+	w := mustDo(t, h, http.StatusUnprocessableEntity, http.MethodPost, "/tasks", `[{"id":"c","title":"z","assignee":"anne"}]`)
+	if !strings.Contains(w.Body.String(), `unknown assignee "anne"`) {
+		t.Errorf("error = %q, want the unknown assignee named", w.Body.String())
+	}
+	mustDo(t, h, http.StatusUnprocessableEntity, http.MethodPut, "/tasks/a", `{"assignee":"anne"}`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"assignee":"bob"}`)
+	mustDo(t, h, http.StatusNotFound, http.MethodGet, "/tasks/c", "")
+
+	_, free := newTestServer(t, nil)
+	createTasks(t, free, `[{"id":"c","title":"z","assignee":"anyone at all"}]`)
+}
+
+func TestReadyzDuringWarmUp(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	release := make(chan struct{})
+	s.warmUp(s.jobs, func() error {
+		<-release
+		return nil
+	})
+
+	var status ReadinessStatus
+	decodeBody(t, mustDo(t, h, http.StatusServiceUnavailable, http.MethodGet, "/readyz", ""), &status)
+	if status.Status != "starting" {
+		t.Errorf("status = %q, want starting", status.Status)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for do(t, h, http.MethodGet, "/readyz", "").Code != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("/readyz did not become ready after warm-up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGroupCount(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[
+		{"id":"a","title":"x","priority":1,"tags":["api","bug"]},
+		{"id":"b","title":"x","priority":1,"tags":["bug"]},
+		{"id":"c","title":"x","priority":3}
+	]`)
+
+	for target, want := range map[string]map[string]int{
+		"/tasks/group-count?by=priority":    {"1": 2, "3": 1},
+		"/tasks/group-count?by=tag":         {"api": 1, "bug": 2},
+		"/tasks/group-count?by=tag&tag=api": {"api": 1, "bug": 1},
+	} {
+		var got map[string]int
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, target, ""), &got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", target, got, want)
+		}
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/group-count?by=title", "")
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/group-count", "")
+}
+
+func TestDuplicateTagsOnUpdate(t *testing.T) {
+	update := `{"tags":["Backend","api","backend ","api"]}`
+
+	_, dedupe := newTestServer(t, nil)
+	createTasks(t, dedupe, `[{"id":"a","title":"x"}]`)
+	var task Task
+	decodeBody(t, mustDo(t, dedupe, http.StatusCreated, http.MethodPut, "/tasks/a", update), &task)
+	if len(task.Tags) != 2 || !slices.Contains(task.Tags, "Backend") || !slices.Contains(task.Tags, "api") {
+		t.Errorf("tags = %q, want Backend and api once each", task.Tags)
+	}
+
+	_, reject := newTestServer(t, func(cfg *Config) { cfg.DuplicateTags = DuplicateTagsReject })
+	createTasks(t, reject, `[{"id":"a","title":"x","tags":["keep"]}]`)
+	w := mustDo(t, reject, http.StatusUnprocessableEntity, http.MethodPut, "/tasks/a", update)
+	if !strings.Contains(w.Body.String(), `"backend "`) {
+		t.Errorf("error = %q, want the case-only duplicate named", w.Body.String())
+	}
+	mustDo(t, reject, http.StatusCreated, http.MethodPut, "/tasks/a", `{"tags":["Backend","api"]}`)
+}
+
+func TestPreferReturn(t *testing.T) {
+	s, h := newTestServer(t, nil)
+
+	w := mustDo(t, h, http.StatusNoContent, http.MethodPost, "/tasks", `[{"id":"a b","title":"x"}]`, "Prefer", "respond-async, return=minimal")
+	if w.Body.Len() != 0 || w.Header().Get("Location") != "/tasks/a%20b" || w.Header().Get("Preference-Applied") != "return=minimal" {
+		t.Errorf("minimal create = %q, headers %v", w.Body.String(), w.Header())
+	}
+	if _, err := s.DB.GetTask("a b"); err != nil {
+		t.Errorf("minimal create did not store the task: %v", err)
+	}
+
+	var created []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks", `[{"id":"b","title":"y"}]`, "Prefer", "return=representation"), &created)
+	if len(created) != 1 || created[0].ID != "b" {
+		t.Errorf("representation create = %+v, want the created task", created)
+	}
+
+	w = mustDo(t, h, http.StatusNoContent, http.MethodPost, "/tasks", `[{"id":"c","title":"z"},{"id":"d","title":"z"}]`, "Prefer", "return=minimal")
+	if w.Header().Get("Location") != "" {
+		t.Errorf("Location = %q for a multi-task create, want none", w.Header().Get("Location"))
+	}
+}
+
+func TestBulkTag(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) { cfg.TagLimit = SoftLimit{Soft: 2, Hard: 3} })
+	createTasks(t, h, `[
+		{"id":"a","title":"x","assignee":"ann","tags":["stale"]},
+		{"id":"b","title":"x","assignee":"ann","tags":["Urgent"]},
+		{"id":"c","title":"x","assignee":"bob","tags":["stale"]}
+	]`)
+	tags := func(ID string) string {
+		task, err := s.DB.GetTask(ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(task.Tags, ",")
+	}
+
+	var result map[string]int
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/tag", `{"filter":{"assignee":"ann"},"add":["urgent","urgent"],"remove":["STALE"]}`), &result)
+	// b already carries urgent in another case, so only a changes.
+	if result["tagged"] != 1 {
+		t.Errorf("tagged = %d, want 1", result["tagged"])
+	}
+	for ID, want := range map[string]string{"a": "urgent", "b": "Urgent", "c": "stale"} {
+		if got := tags(ID); got != want {
+			t.Errorf("%s tags = %q, want %q", ID, got, want)
+		}
+	}
+
+	w := mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/tag", `{"filter":{"assignee":"bob"},"add":["x","y"]}`, "Accept", "application/msgpack")
+	if err := msgpack.Unmarshal(w.Body.Bytes(), &result); err != nil || result["tagged"] != 1 {
+		t.Errorf("msgpack result = %v, %v; want tagged 1", result, err)
+	}
+	if !strings.Contains(w.Header().Get("Warning"), "soft limit") {
+		t.Errorf("Warning = %q, want the soft tag limit reported", w.Header().Get("Warning"))
+	}
+
+	mustDo(t, h, http.StatusUnprocessableEntity, http.MethodPost, "/tasks/tag", `{"add":["z"]}`)
+	if got := tags("a"); got != "urgent" {
+		t.Errorf("the rejected bulk tag still changed a: %q", got)
+	}
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/tag", `{"filter":{}}`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/tag", `{"add":[" "]}`)
+}
+
+func TestBestEffortCreate(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	truncated := `[{"id":"a","title":"x"},{"id":"b","title":"y"},{"id":"c","ti`
+
+	var result BestEffortResult
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks?best_effort=true", truncated), &result)
+	if result.Parsed != 2 || taskIDs(result.Created) != "a,b" || !strings.Contains(result.Error, "element 2") {
+		t.Errorf("result = %+v, want a and b created and element 2 reported", result)
+	}
+	if _, err := s.DB.GetTask("c"); err == nil {
+		t.Error("the truncated element was created")
+	}
+
+	var complete BestEffortResult
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks?best_effort=true", `[{"id":"d","title":"z"}]`), &complete)
+	if complete.Parsed != 1 || complete.Error != "" {
+		t.Errorf("complete array = %+v, want no error", complete)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks?best_effort=true", `[{"id":`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks", `[{"id":"e","title":"x"},{"id":`)
+	if _, err := s.DB.GetTask("e"); err == nil {
+		t.Error("a plain create kept part of a truncated batch")
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	window := &MaintenanceWindow{Name: "db upgrade", Start: start, End: start.Add(2 * time.Hour)}
+	s, h := newTestServer(t, func(cfg *Config) { cfg.Maintenance = window })
+	now := start.Add(-time.Minute)
+	s.now = func() time.Time { return now }
+
+	createTasks(t, h, `[{"id":"a","title":"x"}]`)
+
+	now = start.Add(30 * time.Minute)
+	w := mustDo(t, h, http.StatusServiceUnavailable, http.MethodPut, "/tasks/a", `{"title":"y"}`)
+	if got := w.Header().Get("Retry-After"); got != "5400" {
+		t.Errorf("Retry-After = %q, want the 5400 seconds left in the window", got)
+	}
+	var body MaintenanceResponse
+	decodeBody(t, w, &body)
+	if body.Window.Name != "db upgrade" || !body.Window.End.Equal(window.End) {
+		t.Errorf("body = %+v, want the window named", body)
+	}
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", "")
+
+	window.BlockReads = true
+	mustDo(t, h, http.StatusServiceUnavailable, http.MethodGet, "/tasks/a", "")
+	mustDo(t, h, http.StatusOK, http.MethodGet, "/readyz", "")
+
+	now = window.End
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"y"}`)
+}
+
+func TestOrderedTasks(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[
+		{"id":"c","title":"x","depends_on":["a","b"]},
+		{"id":"b","title":"x","depends_on":["a"]},
+		{"id":"a","title":"x"},
+		{"id":"d","title":"x"},
+		{"id":"e","title":"x","depends_on":["outside"]}
+	]`)
+
+	var ordered []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/ordered", ""), &ordered)
+	if got := taskIDs(ordered); got != "a,b,c,d,e" {
+		t.Errorf("order = %s, want a,b,c,d,e", got)
+	}
+
+	createTasks(t, h, `[
+		{"id":"f","title":"x","depends_on":["g"]},
+		{"id":"g","title":"x","depends_on":["h"]},
+		{"id":"h","title":"x","depends_on":["f"]},
+		{"id":"i","title":"x","depends_on":["f"]}
+	]`)
+	w := mustDo(t, h, http.StatusConflict, http.MethodGet, "/tasks/ordered", "")
+	if got := strings.TrimSpace(w.Body.String()); got != "dependency cycle: f -> g -> h -> f" {
+		t.Errorf("error = %q, want the cycle listed", got)
+	}
+}
+
+func TestNextTaskSkipsOpenDependencies(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[
+		{"id":"deploy","title":"x","assignee":"ann","priority":9,"depends_on":["review","gone"]},
+		{"id":"review","title":"x","assignee":"bob"},
+		{"id":"gone","title":"x"},
+		{"id":"docs","title":"x","assignee":"ann","priority":1,"depends_on":["unknown"]}
+	]`)
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/gone", "")
+
+	next := func() string {
+		var task Task
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/next?assignee=ann", ""), &task)
+		return task.ID
+	}
+
+	if got := next(); got != "docs" {
+		t.Errorf("next = %s while review is open, want docs", got)
+	}
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/review", `{"status":"done"}`)
+	if got := next(); got != "deploy" {
+		t.Errorf("next = %s once review is done, want deploy", got)
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	created := createTasks(t, h, `[{"id":"a","title":"1"},{"id":"b","title":"2"},{"id":"c","title":"3"},{"id":"d","title":"4"},{"id":"e","title":"5"}]`)
+
+	var one countingWriter
+	if err := (JSONCodec{}).Encode(&one, created[0]); err != nil {
+		t.Fatal(err)
+	}
+	s.Config.MaxResponseBytes = 2*one.n + 10
+
+	var walked []string
+	target := "/tasks?sort=id"
+	for target != "" {
+		w := mustDo(t, h, http.StatusOK, http.MethodGet, target, "")
+		if w.Body.Len() > s.Config.MaxResponseBytes {
+			t.Errorf("%s: %d bytes, over the %d byte limit", target, w.Body.Len(), s.Config.MaxResponseBytes)
+		}
+		var page []Task
+		decodeBody(t, w, &page)
+		walked = append(walked, taskIDs(page))
+
+		target = ""
+		if w.Header().Get("X-Truncated") == "true" {
+			target = "/tasks?sort=id&after=" + w.Header().Get("X-Next-Cursor")
+		}
+	}
+	if got := strings.Join(walked, "|"); got != "a,b|c,d|e" {
+		t.Errorf("truncated walk = %s, want a,b|c,d|e", got)
+	}
+
+	s.Config.MaxResponseBytes = 1
+	var page []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?sort=id", ""), &page)
+	if len(page) != 1 {
+		t.Errorf("got %d tasks under a tiny limit, want 1 so paging progresses", len(page))
+	}
+}
+
+func TestTaskAt(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) { cfg.MaxTaskVersions = 2 })
+	createTasks(t, h, `[{"id":"a","title":"v1"}]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"v2"}`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"v3"}`)
+
+	history, _, err := s.DB.GetTaskHistory("a")
+	if err != nil || len(history) != 3 {
+		t.Fatalf("history = %d changes, %v; want 3", len(history), err)
+	}
+	at := func(at time.Time) string { return "/tasks/a?at=" + at.Format(time.RFC3339Nano) }
+
+	var past Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, at(history[1].At), ""), &past)
+	if past.Title != "v2" {
+		t.Errorf("title at the first update = %q, want v2", past.Title)
+	}
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, at(history[2].At.Add(time.Hour)), ""), &past)
+	if past.Title != "v3" {
+		t.Errorf("title after the last update = %q, want v3", past.Title)
+	}
+
+	// Only two versions are read back, so the original one is gone, and
+	// /diff agrees that nothing is known at that moment.
+	mustDo(t, h, http.StatusNotFound, http.MethodGet, at(history[0].At), "")
+	mustDo(t, h, http.StatusNotFound, http.MethodGet, at(history[0].At.Add(-time.Hour)), "")
+	var diff TaskDiff
+	w := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a/diff?from="+history[0].At.Format(time.RFC3339Nano), "")
+	decodeBody(t, w, &diff)
+	if diff.FromKnown || w.Header().Get("Warning") == "" {
+		t.Errorf("diff from the dropped version = %+v, want it unknown with a Warning", diff)
+	}
+	var known TaskDiff
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a/diff?from="+history[1].At.Format(time.RFC3339Nano), ""), &known)
+	if !known.FromKnown || string(known.Diff["title"].Old) != `"v2"` {
+		t.Errorf("diff from a read-back version = %+v, want it known", known)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/a?at=yesterday", "")
+}
+
+// hungNotifier never answers until released, ignoring its context.
+type hungNotifier struct {
+	calls   *atomic.Int32
+	release chan struct{}
+}
+
+func (n hungNotifier) Notify(ctx context.Context, event TaskEvent) error {
+	n.calls.Add(1)
+	<-n.release
+	return nil
+}
+
+func TestNotifierHubDropsHungNotifier(t *testing.T) {
+	hung := hungNotifier{calls: new(atomic.Int32), release: make(chan struct{})}
+	t.Cleanup(func() { close(hung.release) })
+
+	responsive := []recordingNotifier{make(recordingNotifier, 30), make(recordingNotifier, 30), make(recordingNotifier, 30)}
+	hub := NewNotifierHub(30, 2, 200*time.Millisecond, hung, responsive[0], responsive[1], responsive[2])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	// Even while the hung notifier holds its delivery, the others get
+	// event 1 well within the timeout.
+	started := time.Now()
+	hub.Publish(TaskEvent{Seq: 1})
+	for i, events := range responsive {
+		select {
+		case <-events:
+		case <-time.After(time.Second):
+			t.Fatalf("notifier %d missed event 1", i)
+		}
+	}
+	if elapsed := time.Since(started); elapsed >= 200*time.Millisecond {
+		t.Errorf("event 1 took %v, want it not held up by the hung notifier", elapsed)
+	}
+
+	// Once dropped, the hung notifier is never called again, so the one
+	// abandoned call is all it leaks.
+	time.Sleep(250 * time.Millisecond)
+	for seq := uint64(2); seq <= 21; seq++ {
+		hub.Publish(TaskEvent{Seq: seq})
+	}
+	for i, events := range responsive {
+		for seq := uint64(2); seq <= 21; seq++ {
+			select {
+			case event := <-events:
+				if event.Seq != seq {
+					t.Errorf("notifier %d got event %d, want %d", i, event.Seq, seq)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("notifier %d missed event %d", i, seq)
+			}
+		}
+	}
+	if calls := hung.calls.Load(); calls != 1 {
+		t.Errorf("hung notifier called %d times, want it dropped after 1", calls)
+	}
+}
+
+func TestPatchPreview(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) {
+		cfg.Assignees = []string{"ann"}
+		cfg.TagLimit = SoftLimit{Hard: 2}
+	})
+	createTasks(t, h, `[{"id":"a","title":"x","tags":["api"]}]`)
+
+	preview := func(patch string) PatchPreview {
+		t.Helper()
+		var result PatchPreview
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/a/patch/preview", patch), &result)
+		return result
+	}
+
+	ok := preview(`[{"op":"replace","path":"/title","value":"y"},{"op":"add","path":"/tags/-","value":"API"}]`)
+	if !ok.Valid || ok.Task == nil || ok.Task.Title != "y" || strings.Join(ok.Task.Tags, ",") != "api" {
+		t.Errorf("preview = %+v, want the new title and the duplicate tag folded", ok)
+	}
+
+	for patch, want := range map[string]string{
+		`[{"op":"remove","path":"/title"}]`:                        "title",
+		`[{"op":"add","path":"/merged_into","value":"b"}]`:         "merged_into cannot be changed",
+		`[{"op":"replace","path":"/remaining_minutes","value":5}]`: "remaining_minutes cannot be changed",
+		`[{"op":"add","path":"/assignee","value":"bob"}]`:          `unknown assignee "bob"`,
+		`[{"op":"replace","path":"/tags","value":["a","b","c"]}]`:  "exceeds the limit of 2",
+		`[{"op":"test","path":"/title","value":"not the title"}]`:  "test",
+	} {
+		result := preview(patch)
+		if result.Valid || !strings.Contains(strings.Join(result.Errors, "; "), want) {
+			t.Errorf("%s: preview = %+v, want an error mentioning %q", patch, result, want)
+		}
+	}
+
+	stored, err := s.DB.GetTask("a")
+	if err != nil || stored.Title != "x" || strings.Join(stored.Tags, ",") != "api" {
+		t.Errorf("stored task = %+v, %v; want it untouched by previews", stored, err)
+	}
+	mustDo(t, h, http.StatusNotFound, http.MethodPost, "/tasks/missing/patch/preview", `[]`)
+
+	// A status change previews the transition timestamps the write sets.
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	done := preview(`[{"op":"replace","path":"/status","value":"done"}]`)
+	if !done.Valid || done.Task.CompletedAt == nil || !done.Task.CompletedAt.Equal(now) || !done.Task.UpdatedAt.Equal(now) {
+		t.Errorf("status preview = %+v, want completed_at and updated_at set to now", done.Task)
+	}
+
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/a", "")
+	mustDo(t, h, http.StatusConflict, http.MethodPost, "/tasks/a/patch/preview", `[{"op":"replace","path":"/title","value":"y"}]`)
+}
+
+func TestNonFiniteNumbers(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","position":1},{"id":"b","title":"y","position":2}]`)
+
+	// JSON cannot carry NaN or infinities, but msgpack can.
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		for _, field := range []string{"estimate_minutes", "position"} {
+			var body bytes.Buffer
+			if err := (MsgpackCodec{}).Encode(&body, map[string]interface{}{field: value}); err != nil {
+				t.Fatal(err)
+			}
+			w := mustDo(t, h, http.StatusUnprocessableEntity, http.MethodPut, "/tasks/a", body.String(), "Content-Type", "application/msgpack")
+			if !strings.Contains(w.Body.String(), field) {
+				t.Errorf("%s=%v: error %q does not name the field", field, value, w.Body.String())
+			}
+		}
+	}
+	mustDo(t, h, http.StatusBadRequest, http.MethodPut, "/tasks/a", `{"position":1.5}`)
+
+	for range 101 {
+		mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/swap", `{"a":"a","b":"b"}`)
+	}
+	var tasks []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?sort=id", ""), &tasks)
+	if len(tasks) != 2 || tasks[0].Position != 2 || tasks[1].Position != 1 {
+		t.Errorf("positions after 101 swaps = %+v, want a and b exchanged once", tasks)
+	}
+	if a, _ := s.DB.GetTask("a"); a.EstimateMinutes != 0 {
+		t.Errorf("the rejected updates changed the estimate to %d", a.EstimateMinutes)
+	}
+}
+
+func TestDefaultPriorities(t *testing.T) {
+	_, h := newTestServer(t, func(cfg *Config) {
+		cfg.DefaultPriorities = map[string]int{InitialStatus: 3, "blocked": 9}
+	})
+
+	created := createTasks(t, h, `[{"id":"a","title":"x"},{"id":"b","title":"y","priority":7},{"id":"c","title":"z","priority":0},{"id":"d","title":"w","status":"blocked"}]`)
+	for i, want := range []int{3, 7, 0, 9} {
+		if created[i].Priority != want {
+			t.Errorf("task %s priority = %d, want %d", created[i].ID, created[i].Priority, want)
+		}
+	}
+	if created[3].Status != "blocked" {
+		t.Errorf("task d status = %q, want it created as blocked", created[3].Status)
+	}
+
+	var packed bytes.Buffer
+	if err := (MsgpackCodec{}).Encode(&packed, []map[string]interface{}{{"id": "e", "title": "packed", "priority": 0}, {"id": "f", "title": "packed"}}); err != nil {
+		t.Fatal(err)
+	}
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks", packed.String(), "Content-Type", "application/msgpack")
+	for ID, want := range map[string]int{"e": 0, "f": 3} {
+		var task Task
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/"+ID, ""), &task)
+		if task.Priority != want {
+			t.Errorf("msgpack task %s priority = %d, want %d", ID, task.Priority, want)
+		}
+	}
+
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/import", `[{"id":"i","title":"z","external_id":"EXT-1"},{"id":"j","title":"z","priority":0}]`)
+	for ID, want := range map[string]int{"i": 3, "j": 0} {
+		var imported Task
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/"+ID, ""), &imported)
+		if imported.Priority != want {
+			t.Errorf("imported task %s priority = %d, want %d", ID, imported.Priority, want)
+		}
+	}
+
+	// Re-importing over the stored task must not reset a priority set since.
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/i", `{"priority":5}`)
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/import?on_existing=update", `[{"id":"i","title":"renamed","external_id":"EXT-1"}]`)
+	var synced Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/i", ""), &synced)
+	if synced.Title != "renamed" || synced.Priority != 5 {
+		t.Errorf("import update = %q priority %d, want the new title and the stored priority 5", synced.Title, synced.Priority)
+	}
+
+	var moved Task
+	decodeBody(t, mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"status":"blocked"}`), &moved)
+	if moved.Priority != 3 {
+		t.Errorf("priority after a status change = %d, want it left at 3", moved.Priority)
+	}
+
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks", `[{"id":"k","title":"x","status":"archived"}]`)
+}
+
+func TestListETag(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","assignee":"ann"},{"id":"b","title":"y","assignee":"bob"}]`)
+
+	w := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?assignee=ann", "")
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("ETag = %q, want a strong tag", etag)
+	}
+	if other := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?assignee=bob", "").Header().Get("ETag"); other == etag {
+		t.Error("different filters share an ETag")
+	}
+
+	w = mustDo(t, h, http.StatusNotModified, http.MethodGet, "/tasks?assignee=ann", "", "If-None-Match", `"stale", `+etag)
+	if w.Body.Len() != 0 {
+		t.Errorf("304 wrote a body: %q", w.Body.String())
+	}
+
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/b", `{"title":"y2"}`)
+	mustDo(t, h, http.StatusNotModified, http.MethodGet, "/tasks?assignee=ann", "", "If-None-Match", etag)
+
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"x2"}`)
+	w = mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?assignee=ann", "", "If-None-Match", etag)
+	if w.Header().Get("ETag") == etag {
+		t.Error("ETag did not change with a matching task")
+	}
+
+	// A lock expiring changes the response without a write, so it must
+	// change the tag too.
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/a/lock", `{"owner":"w1","ttl_seconds":60}`)
+	etag = mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?assignee=ann", "").Header().Get("ETag")
+	now = now.Add(2 * time.Minute)
+	w = mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?assignee=ann", "", "If-None-Match", etag)
+	if strings.Contains(w.Body.String(), "locked_by") {
+		t.Errorf("expired lock still listed: %s", w.Body.String())
+	}
+}
+
+func TestTaskLocks(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	createTasks(t, h, `[{"id":"a","title":"x"},{"id":"b","title":"y"}]`)
+
+	var locked Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/a/lock", `{"owner":"w1","ttl_seconds":60}`), &locked)
+	if locked.LockedBy != "w1" || locked.LockExpiresAt == nil || !locked.LockExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("locked = %+v, want w1 holding it for a minute", locked)
+	}
+
+	mustDo(t, h, http.StatusConflict, http.MethodPost, "/tasks/a/lock", `{"owner":"w2"}`)
+	mustDo(t, h, http.StatusConflict, http.MethodPost, "/tasks/a/unlock", `{"owner":"w2"}`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/a/lock", `{}`)
+
+	var unlockedIDs []string
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?exclude_locked=true&id_only=true", ""), &unlockedIDs)
+	if strings.Join(unlockedIDs, ",") != "b" {
+		t.Errorf("exclude_locked = %v, want only b", unlockedIDs)
+	}
+
+	now = now.Add(time.Minute)
+	var expired Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", ""), &expired)
+	if expired.LockedBy != "" || expired.LockExpiresAt != nil {
+		t.Errorf("expired lock still shown: %+v", expired)
+	}
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?exclude_locked=true&id_only=true&sort=id", ""), &unlockedIDs)
+	if strings.Join(unlockedIDs, ",") != "a,b" {
+		t.Errorf("exclude_locked after expiry = %v, want a,b", unlockedIDs)
+	}
+
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/a/lock", `{"owner":"w2"}`), &locked)
+	if locked.LockedBy != "w2" {
+		t.Errorf("lock after expiry = %+v, want w2", locked)
+	}
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/a/unlock", `{"owner":"w2"}`)
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/a/lock", `{"owner":"w1"}`)
+}
+
+func TestLifecycleShutdown(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	jobs := NewLifecycle()
+
+	events := make(recordingNotifier, 1)
+	hub := NewNotifierHub(10, 1, time.Second, events)
+	hub.Publish(TaskEvent{Seq: 1})
+	jobs.Go("notifications", hub.Run)
+
+	var flushed atomic.Bool
+	jobs.Go("archiver", func(ctx context.Context) {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				flushed.Store(true)
+				return
+			}
+		}
+	})
+
+	if err := jobs.Shutdown(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !flushed.Load() {
+		t.Error("archiver did not finish its work before Shutdown returned")
+	}
+	select {
+	case <-events:
+	default:
+		t.Error("queued notification was dropped on shutdown")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after shutdown, want %d", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLifecycleShutdownTimeout(t *testing.T) {
+	jobs := NewLifecycle()
+	release := make(chan struct{})
+	defer close(release)
+	jobs.Go("stubborn", func(ctx context.Context) { <-release })
+	jobs.Go("polite", func(ctx context.Context) { <-ctx.Done() })
+
+	started := time.Now()
+	err := jobs.Shutdown(20 * time.Millisecond)
+	if err == nil || !strings.HasSuffix(err.Error(), ": stubborn") {
+		t.Errorf("Shutdown = %v, want the stubborn job named", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Shutdown took %v past its timeout", elapsed)
+	}
+}
+
+func TestTrendBucketsAcrossDays(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip(err)
+	}
+	utc := func(day, hour int) *time.Time {
+		at := time.Date(2026, 3, day, hour, 0, 0, 0, time.UTC)
+		return &at
+	}
+
+	// Tokyo is UTC+9, so its days start at 15:00 UTC the day before.
+	tasks := []Task{
+		{ID: "a", CreatedAt: *utc(1, 14)},
+		{ID: "b", CreatedAt: *utc(1, 16), CompletedAt: utc(2, 14)},
+		{ID: "c", CreatedAt: *utc(2, 16), ArchivedAt: utc(3, 9)},
+		{ID: "too-old", CreatedAt: *utc(0, 14)},
+	}
+
+	buckets, err := trendBuckets(trendSpan{days: 3}, trendSpan{days: 1}, *utc(3, 10), tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	countTrend(tasks, buckets)
+
+	want := []TrendBucket{
+		{Created: 1},
+		{Created: 1, Completed: 1},
+		{Created: 1, Archived: 1},
+	}
+	if len(buckets) != len(want) {
+		t.Fatalf("got %d buckets, want %d", len(buckets), len(want))
+	}
+	for i, bucket := range buckets {
+		if start := time.Date(2026, 3, 1+i, 0, 0, 0, 0, tokyo); !bucket.Start.Equal(start) {
+			t.Errorf("bucket %d starts %v, want Tokyo midnight %v", i, bucket.Start, start)
+		}
+		if bucket.Created != want[i].Created || bucket.Completed != want[i].Completed || bucket.Archived != want[i].Archived {
+			t.Errorf("bucket %d = %+v, want %+v", i, bucket, want[i])
+		}
+	}
+}
+
+func TestTrendEndpoint(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x"},{"id":"b","title":"y"}]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"status":"done"}`)
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/b", "")
+
+	var trend TrendResponse
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/trend", ""), &trend)
+	if len(trend.Buckets) != 7 || trend.Window != "7d" || trend.Bucket != "1d" {
+		t.Fatalf("trend = %+v, want 7 daily buckets by default", trend)
+	}
+	if today := trend.Buckets[6]; today.Created != 2 || today.Completed != 1 || today.Archived != 1 {
+		t.Errorf("today = %+v, want 2 created, 1 completed, 1 archived", today)
+	}
+
+	// A clock a week ahead leaves today's tasks out of the window.
+	s.now = func() time.Time { return time.Now().AddDate(0, 0, 7) }
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/trend?window=48h&bucket=12h", ""), &trend)
+	for i, bucket := range trend.Buckets {
+		if bucket.Created != 0 {
+			t.Errorf("bucket %d = %+v, want it empty", i, bucket)
+		}
+	}
+
+	for _, query := range []string{"window=7d&bucket=2d", "window=0d", "bucket=soon", "window=1000h&bucket=1m", "window=1h&bucket=1d"} {
+		mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks/trend?"+query, "")
+	}
+}
+
+func TestRetryAfterFormats(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	window := &MaintenanceWindow{Start: now.Add(-time.Hour), End: now.Add(90*time.Second + 200*time.Millisecond)}
+	wantAt := now.Add(91 * time.Second)
+
+	for _, tc := range []struct {
+		config, prefer string
+		httpDate       bool
+	}{
+		{RetryAfterSeconds, "", false},
+		{RetryAfterSeconds, "retry-after=HTTP-Date", true},
+		{RetryAfterHTTPDate, "", true},
+		{RetryAfterHTTPDate, "return=minimal, retry-after=seconds", false},
+	} {
+		s, h := newTestServer(t, func(cfg *Config) {
+			cfg.RetryAfterFormat = tc.config
+			cfg.Maintenance = window
+		})
+		s.now = func() time.Time { return now }
+
+		got := mustDo(t, h, http.StatusServiceUnavailable, http.MethodPost, "/tasks", `[]`, "Prefer", tc.prefer).Header().Get("Retry-After")
+		if !tc.httpDate {
+			if got != "91" {
+				t.Errorf("%s/%q: Retry-After = %q, want 91 seconds", tc.config, tc.prefer, got)
+			}
+			continue
+		}
+		if at, err := http.ParseTime(got); err != nil || !at.Equal(wantAt) {
+			t.Errorf("%s/%q: Retry-After = %q, want the HTTP date %v", tc.config, tc.prefer, got, wantAt)
+		}
+	}
+}
+
+func TestUpdateBodyLimits(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) { cfg.MaxUpdateBytes = 64 })
+	long := strings.Repeat("x", 100)
+	createTasks(t, h, `[{"id":"a","title":"`+long+`"}]`)
+
+	w := mustDo(t, h, http.StatusRequestEntityTooLarge, http.MethodPut, "/tasks/a", `{"title":"`+long+`"}`)
+	if !strings.Contains(w.Body.String(), "64 bytes") {
+		t.Errorf("error = %q, want the limit named", w.Body.String())
+	}
+
+	for _, nested := range []string{`{"title":{"text":{"y":1}}}`, `{"tags":[["y"]]}`, `{"meta":{"a":{"b":1}}}`} {
+		w := mustDo(t, h, http.StatusUnprocessableEntity, http.MethodPut, "/tasks/a", nested)
+		if !strings.Contains(w.Body.String(), ErrNestedValue.Error()) {
+			t.Errorf("%s: error = %q, want a nesting error", nested, w.Body.String())
+		}
+	}
+
+	// A batch item is an update body too and gets the same checks.
+	w = mustDo(t, h, http.StatusUnprocessableEntity, http.MethodPost, "/tasks/batch-update", `[{"id":"a","data":{"title":"ok"}},{"id":"a","data":{"meta":{"a":{"b":{"c":1}}}}}]`)
+	if !strings.HasPrefix(w.Body.String(), "item 1: ") || !strings.Contains(w.Body.String(), ErrNestedValue.Error()) {
+		t.Errorf("batch error = %q, want a nesting error for item 1", w.Body.String())
+	}
+
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"title":"short","tags":["y"]}`)
+	if a, _ := s.DB.GetTask("a"); a.Title != "short" {
+		t.Errorf("title = %q after a valid update", a.Title)
+	}
+}
+
+func TestAssigneesList(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[
+		{"id":"a","title":"x","assignee":"bob"},
+		{"id":"b","title":"x","assignee":"ann"},
+		{"id":"c","title":"x","assignee":"bob"},
+		{"id":"d","title":"x"},
+		{"id":"e","title":"x","assignee":"cid"}
+	]`)
+	mustDo(t, h, http.StatusNoContent, http.MethodDelete, "/tasks/e", "")
+
+	var got AssigneesResponse
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/assignees", ""), &got)
+	want := AssigneesResponse{
+		Assignees:  []AssigneeCount{{Assignee: "ann", Count: 1}, {Assignee: "bob", Count: 2}},
+		Unassigned: 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("assignees = %+v, want %+v", got, want)
+	}
+}
+
+func TestStatusAliases(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) {
+		cfg.StatusAliases = map[string]string{"open": InitialStatus, "wip": "in_progress", "finished": "done"}
+	})
+	createTasks(t, h, `[
+		{"id":"a","title":"x","assignee":"ann","priority":1},
+		{"id":"b","title":"x","assignee":"ann","priority":2},
+		{"id":"c","title":"x","assignee":"ann","priority":9},
+		{"id":"d","title":"x","assignee":"ann","priority":9}
+	]`)
+	for ID, status := range map[string]string{"a": "open", "b": "wip", "c": "finished", "d": "blocked"} {
+		mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/"+ID, `{"status":"`+status+`"}`)
+	}
+	if stored, _ := s.DB.GetTask("b"); stored.Status != "wip" {
+		t.Fatalf("stored status = %q, want the legacy value kept", stored.Status)
+	}
+
+	for ID, want := range map[string]string{"a": InitialStatus, "b": "in_progress", "c": "done", "d": "blocked"} {
+		var task Task
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/"+ID, ""), &task)
+		if task.Status != want {
+			t.Errorf("%s status = %q, want %q", ID, task.Status, want)
+		}
+	}
+
+	for _, status := range []string{"in_progress", "wip"} {
+		var ids []string
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?id_only=true&status="+status, ""), &ids)
+		if strings.Join(ids, ",") != "b" {
+			t.Errorf("status=%s = %v, want b", status, ids)
+		}
+	}
+
+	var groups map[string]int
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/group-count?by=status", ""), &groups)
+	if want := map[string]int{InitialStatus: 1, "in_progress": 1, "done": 1, "blocked": 1}; !reflect.DeepEqual(groups, want) {
+		t.Errorf("group-count = %v, want %v", groups, want)
+	}
+
+	if got := mustDo(t, h, http.StatusOK, http.MethodHead, "/tasks", "").Header().Get("X-Count-Created"); got != "1" {
+		t.Errorf("X-Count-Created = %q, want the open task counted", got)
+	}
+
+	var next Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/next?assignee=ann", ""), &next)
+	if next.ID != "b" {
+		t.Errorf("next = %s, want b since c is finished and d blocked", next.ID)
+	}
+
+	var workload []AssigneeWorkload
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/workload", ""), &workload)
+	if len(workload) != 1 || workload[0].Open != 3 || workload[0].InProgress != 1 {
+		t.Errorf("workload = %+v, want 3 open with 1 in progress", workload)
+	}
+
+	// b is stored as "wip"; both the alias and the canonical name select it.
+	for _, step := range []struct{ from, to, status string }{{"ann", "bob", "wip"}, {"bob", "cid", "in_progress"}} {
+		var result map[string]int
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/reassign", `{"from":"`+step.from+`","to":"`+step.to+`","status":"`+step.status+`"}`), &result)
+		if result["reassigned"] != 1 {
+			t.Errorf("reassign with status %s = %d, want 1", step.status, result["reassigned"])
+		}
+	}
+	if b, _ := s.DB.GetTask("b"); b.Assignee != "cid" {
+		t.Errorf("b assignee = %q after reassigning by status, want cid", b.Assignee)
+	}
+}
+
+func TestFieldSelection(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","tags":["t"],"assignee":"ann"}]`)
+
+	var flat []map[string]interface{}
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?fields=id,tags", ""), &flat)
+	if want := []map[string]interface{}{{"id": "a", "tags": []interface{}{"t"}}}; !reflect.DeepEqual(flat, want) {
+		t.Errorf("flat selection = %v, want %v", flat, want)
+	}
+
+	var nested map[string]interface{}
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/changes?fields=last_seq,changes(seq,task(id,title))", ""), &nested)
+	want := map[string]interface{}{
+		"last_seq": float64(1),
+		"changes":  []interface{}{map[string]interface{}{"seq": float64(1), "task": map[string]interface{}{"id": "a", "title": "x"}}},
+	}
+	if !reflect.DeepEqual(nested, want) {
+		t.Errorf("nested selection = %v, want %v", nested, want)
+	}
+
+	for _, malformed := range []string{"id,", "task(id", "id)", "id,id", "ta sk", "task()"} {
+		mustDo(t, h, http.StatusBadRequest, http.MethodGet, "/tasks?fields="+url.QueryEscape(malformed), "")
+	}
+	mustDo(t, h, http.StatusBadRequest, http.MethodPut, "/tasks/a?fields=id,", `{"title":"y"}`)
+	if a, _ := s.DB.GetTask("a"); a.Title != "x" {
+		t.Error("a write with a malformed selection was applied")
+	}
+}
+
+func TestSwapPositions(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x","position":1},{"id":"b","title":"y","position":5},{"id":"c","title":"z","position":9}]`)
+
+	var swapped []Task
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/swap", `{"a":"a","b":"b"}`), &swapped)
+	if len(swapped) != 2 || swapped[0].ID != "a" || swapped[0].Position != 5 || swapped[1].ID != "b" || swapped[1].Position != 1 {
+		t.Errorf("swapped = %+v, want a at 5 and b at 1", swapped)
+	}
+
+	mustDo(t, h, http.StatusNotFound, http.MethodPost, "/tasks/swap", `{"a":"a","b":"missing"}`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/swap", `{"a":"a","b":"a"}`)
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/swap", `{"a":"a"}`)
+
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/c", `{"status":"done"}`)
+	mustDo(t, h, http.StatusConflict, http.MethodPost, "/tasks/swap", `{"a":"a","b":"c"}`)
+	if a, _ := s.DB.GetTask("a"); a.Position != 5 {
+		t.Errorf("the rejected swap moved a to %d", a.Position)
+	}
+}
+
+func TestEmptyFieldsPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		header bool
+		want   map[string]string // "" means the field is omitted
+	}{
+		{EmptyFieldsDefault, false, map[string]string{"assignee": `""`, "due_at": "", "tags": ""}},
+		{EmptyFieldsOmit, false, map[string]string{"assignee": "", "due_at": "", "tags": ""}},
+		{EmptyFieldsExplicitNull, true, map[string]string{"assignee": "null", "due_at": "null", "tags": "null"}},
+		{EmptyFieldsAlwaysPresent, true, map[string]string{"assignee": `""`, "due_at": "null", "tags": "[]"}},
+	} {
+		var headers []string
+		_, h := newTestServer(t, func(cfg *Config) {
+			if !tc.header {
+				cfg.EmptyFields = tc.policy
+			}
+		})
+		if tc.header {
+			headers = []string{"Accept", "application/json; empty-fields=" + tc.policy}
+		}
+		createTasks(t, h, `[{"id":"a","title":"x"}]`)
+
+		var fields map[string]json.RawMessage
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/a", "", headers...), &fields)
+		if string(fields["title"]) != `"x"` {
+			t.Errorf("%s: title = %s, want it kept", tc.policy, fields["title"])
+		}
+		for field, want := range tc.want {
+			if got, ok := fields[field]; want == "" && ok {
+				t.Errorf("%s: %s = %s, want it omitted", tc.policy, field, got)
+			} else if want != "" && string(got) != want {
+				t.Errorf("%s: %s = %s, want %s", tc.policy, field, got, want)
+			}
+		}
+	}
+}
+
+func TestBulkArchiveConfirm(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	createTasks(t, h, `[
+		{"id":"a","title":"x","assignee":"ann"},
+		{"id":"b","title":"x","assignee":"ann"},
+		{"id":"c","title":"x","assignee":"bob"}
+	]`)
+
+	count := mustDo(t, h, http.StatusOK, http.MethodHead, "/tasks?assignee=ann", "").Header().Get("X-Total-Count")
+
+	var mismatch BulkArchiveMismatch
+	decodeBody(t, mustDo(t, h, http.StatusConflict, http.MethodPost, "/tasks/bulk-archive-by-filter", `{"filter":{"assignee":"ann"},"confirm":3}`), &mismatch)
+	if mismatch.Count != 2 || mismatch.Confirm != 3 {
+		t.Errorf("mismatch = %+v, want the actual count 2", mismatch)
+	}
+	if a, _ := s.DB.GetTask("a"); a.ArchivedAt != nil {
+		t.Fatal("a mismatched confirm archived tasks")
+	}
+	mustDo(t, h, http.StatusBadRequest, http.MethodPost, "/tasks/bulk-archive-by-filter", `{"filter":{"assignee":"ann"}}`)
+
+	var result map[string]int
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/bulk-archive-by-filter", `{"filter":{"assignee":"ann"},"reason":"cleanup","confirm":`+count+`}`), &result)
+	if result["archived"] != 2 {
+		t.Errorf("archived = %d, want 2", result["archived"])
+	}
+	for ID, archived := range map[string]bool{"a": true, "b": true, "c": false} {
+		task, _ := s.DB.GetTask(ID)
+		if (task.ArchivedAt != nil) != archived || (archived && task.ArchiveReason != "cleanup") {
+			t.Errorf("%s = archived %v reason %q, want archived %v", ID, task.ArchivedAt != nil, task.ArchiveReason, archived)
+		}
+	}
+}
+
+// waitOperation polls an operation until it has finished.
+func waitOperation(t *testing.T, h http.Handler, location string) Operation {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var op Operation
+		decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, location, ""), &op)
+		if op.FinishedAt != nil {
+			return op
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("operation still %s: %+v", op.Status, op)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func importBody(n int) string {
+	var body strings.Builder
+	body.WriteString("[")
+	for i := range n {
+		if i > 0 {
+			body.WriteString(",")
+		}
+		body.WriteString(`{"id":"t` + strconv.Itoa(i) + `","title":"x","external_id":"e` + strconv.Itoa(i) + `"}`)
+	}
+	body.WriteString("]")
+	return body.String()
+}
+
+func TestAsyncImport(t *testing.T) {
+	s, h := newTestServer(t, nil)
+
+	w := mustDo(t, h, http.StatusAccepted, http.MethodPost, "/tasks/import?async=true", importBody(2*importChunkSize+10))
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, "/operations/") {
+		t.Fatalf("Location = %q", location)
+	}
+
+	// Pollers read the operation while the import updates it; run with
+	// -race to check they only ever see copies.
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				if w := do(t, h, http.MethodGet, location, ""); w.Code != http.StatusOK {
+					t.Errorf("poll: status %d: %s", w.Code, w.Body.String())
+					return
+				}
+			}
+		}()
+	}
+
+	op := waitOperation(t, h, location)
+	wg.Wait()
+	if op.Status != OperationSucceeded || op.Processed != op.Total || op.Result == nil || op.Result.Created != op.Total {
+		t.Errorf("operation = %+v, want every task created", op)
+	}
+	if tasks, _ := s.DB.GetTasks(TaskFilter{}); len(tasks) != op.Total {
+		t.Errorf("stored %d tasks, want %d", len(tasks), op.Total)
+	}
+	mustDo(t, h, http.StatusConflict, http.MethodDelete, location, "")
+	mustDo(t, h, http.StatusNotFound, http.MethodGet, "/operations/unknown", "")
+}
+
+func TestAsyncImportQueuesAndStops(t *testing.T) {
+	s, h := newTestServer(t, func(cfg *Config) { cfg.MaxConcurrentImports = 1 })
+	s.imports <- struct{}{}
+
+	// With the only slot taken a sync import is turned away, but async
+	// ones queue.
+	mustDo(t, h, http.StatusTooManyRequests, http.MethodPost, "/tasks/import", importBody(1))
+	cancelled := mustDo(t, h, http.StatusAccepted, http.MethodPost, "/tasks/import?async=true", importBody(1)).Header().Get("Location")
+	stopped := mustDo(t, h, http.StatusAccepted, http.MethodPost, "/tasks/import?async=true", importBody(1)).Header().Get("Location")
+
+	s.readOnly.Store(true)
+	mustDo(t, h, http.StatusAccepted, http.MethodDelete, cancelled, "")
+	if op := waitOperation(t, h, cancelled); op.Status != OperationCancelled {
+		t.Errorf("cancelled operation = %+v", op)
+	}
+
+	<-s.imports
+	op := waitOperation(t, h, stopped)
+	if op.Status != OperationFailed || !strings.Contains(op.Error, "read-only") {
+		t.Errorf("operation during read-only mode = %+v, want it stopped", op)
+	}
+	if tasks, _ := s.DB.GetTasks(TaskFilter{}); len(tasks) != 0 {
+		t.Errorf("stored %d tasks in read-only mode", len(tasks))
+	}
+}
+
+func TestSchemaVersions(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	v1 := []string{"X-Schema-Version", "1"}
+	ndjson := []string{"X-Schema-Version", "1", "Content-Type", "application/x-ndjson"}
+
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks", `[{"id":"a","title":"x","tag":"api"}]`, v1...)
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks?best_effort=true", `[{"id":"b","title":"x","tag":"api"}]`, v1...)
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/import", `[{"id":"c","title":"x","tag":"api"}]`, v1...)
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/import", `{"id":"d","title":"x","tag":"api"}`+"\n", ndjson...)
+	mustDo(t, h, http.StatusCreated, http.MethodPost, "/tasks?upsert_by=external_id", `[{"id":"e","title":"x","external_id":"ext-e","tag":"api"}]`, v1...)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"tag":"ops"}`, v1...)
+	mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/batch-update", `[{"id":"b","data":{"tag":"ops"}}]`, v1...)
+
+	for ID, want := range map[string]string{"a": "ops", "b": "ops", "c": "api", "d": "api", "e": "api"} {
+		task, err := s.DB.GetTask(ID)
+		if err != nil || strings.Join(task.Tags, ",") != want {
+			t.Errorf("%s = %+v, %v; want the v1 tag migrated to tags [%s]", ID, task, err, want)
+		}
+	}
+
+	var results []ValidationResult
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/validate-batch", `[{"id":"f","title":"x","tag":"api"}]`, v1...), &results)
+	if len(results) != 1 || !results[0].Valid {
+		t.Errorf("validate-batch of a v1 task = %+v, want it valid", results)
+	}
+
+	unknown := []string{"X-Schema-Version", "9"}
+	for _, write := range []struct{ method, target, body string }{
+		{http.MethodPost, "/tasks", `[]`},
+		{http.MethodPost, "/tasks?best_effort=true", `[]`},
+		{http.MethodPost, "/tasks/import", `[]`},
+		{http.MethodPut, "/tasks/a", `{}`},
+		{http.MethodPost, "/tasks/batch-update", `[]`},
+		{http.MethodPost, "/tasks/validate-batch", `[]`},
+	} {
+		w := mustDo(t, h, http.StatusBadRequest, write.method, write.target, write.body, unknown...)
+		if !strings.Contains(w.Body.String(), `unknown schema version "9"`) {
+			t.Errorf("%s %s: error = %q", write.method, write.target, w.Body.String())
+		}
+	}
+}
+
+func TestFlowTimes(t *testing.T) {
+	created := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	task := Task{ID: "a", Status: InitialStatus, CreatedAt: created}
+
+	for _, step := range []struct {
+		status string
+		after  time.Duration
+	}{
+		{"in_progress", time.Hour},
+		{"blocked", 2 * time.Hour},
+		{"in_progress", 3 * time.Hour},
+		{"done", 5 * time.Hour},
+	} {
+		task.Status = step.status
+		markTransition(&task, created.Add(step.after))
+	}
+
+	lead, cycle := flowTimes(task)
+	if lead == nil || *lead != 5*3600 || cycle == nil || *cycle != 4*3600 {
+		t.Errorf("lead, cycle = %v, %v; want 5h and 4h from the first start", lead, cycle)
+	}
+
+	task.Status = "in_progress"
+	markTransition(&task, created.Add(6*time.Hour))
+	if lead, cycle := flowTimes(task); lead != nil || cycle != nil {
+		t.Errorf("reopened task still has lead %v, cycle %v", lead, cycle)
+	}
+}
+
+func TestFlowMetricsPercentiles(t *testing.T) {
+	created := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var tasks []Task
+	for i := 1; i <= 20; i++ {
+		started := created.Add(time.Duration(i) * time.Second)
+		done := started.Add(time.Duration(i) * time.Second)
+		tasks = append(tasks, Task{ID: strconv.Itoa(i), CreatedAt: created, StartedAt: &started, CompletedAt: &done})
+	}
+	done := created.Add(time.Minute)
+	tasks = append(tasks, Task{ID: "never-started", CreatedAt: created, CompletedAt: &done}, Task{ID: "open", CreatedAt: created})
+
+	metrics := computeFlowMetrics(tasks)
+	// Lead times are 2, 4, ..., 40 seconds plus 60; cycle times 1..20.
+	if want := (FlowStats{Count: 21, Avg: 480.0 / 21, P50: 22, P85: 36, P95: 40}); metrics.LeadTime != want {
+		t.Errorf("lead time = %+v, want %+v", metrics.LeadTime, want)
+	}
+	if want := (FlowStats{Count: 20, Avg: 10.5, P50: 10, P85: 17, P95: 19}); metrics.CycleTime != want {
+		t.Errorf("cycle time = %+v, want %+v", metrics.CycleTime, want)
+	}
+}
+
+func TestFlowMetricsEndpoint(t *testing.T) {
+	_, h := newTestServer(t, nil)
+	createTasks(t, h, `[{"id":"a","title":"x"},{"id":"b","title":"y"}]`)
+	mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"status":"in_progress"}`)
+
+	var done Task
+	decodeBody(t, mustDo(t, h, http.StatusCreated, http.MethodPut, "/tasks/a", `{"status":"done"}`), &done)
+	if done.StartedAt == nil || done.CompletedAt == nil || done.LeadTimeSeconds == nil || done.CycleTimeSeconds == nil {
+		t.Errorf("done task = %+v, want transition times and flow times", done)
+	}
+
+	var metrics FlowMetrics
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/flow-metrics", ""), &metrics)
+	if metrics.LeadTime.Count != 1 || metrics.CycleTime.Count != 1 {
+		t.Errorf("metrics = %+v, want only the done task counted", metrics)
+	}
+}
+
+func TestSoftAndHardLimits(t *testing.T) {
+	_, h := newTestServer(t, func(cfg *Config) {
+		cfg.TagLimit = SoftLimit{Soft: 2, Hard: 3}
+		cfg.BatchLimit = SoftLimit{Soft: 2, Hard: 3}
+	})
+	createTasks(t, h, `[{"id":"target","title":"x"}]`)
+
+	next := 0
+	tasks := func(n int) string {
+		parts := make([]string, n)
+		for i := range parts {
+			next++
+			parts[i] = `{"id":"t` + strconv.Itoa(next) + `","title":"x"}`
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	tagged := func(n int) string {
+		next++
+		return `[{"id":"t` + strconv.Itoa(next) + `","title":"x","tags":` + tagList(n) + `}]`
+	}
+	batch := func(n int) string {
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = `{"id":"target","data":{"title":"y"}}`
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+
+	for _, tc := range []struct {
+		name, method, target string
+		ok                   int
+		tooLarge             int
+		body                 func(n int) string
+	}{
+		{"create batch", http.MethodPost, "/tasks", http.StatusOK, http.StatusRequestEntityTooLarge, tasks},
+		{"best-effort batch", http.MethodPost, "/tasks?best_effort=true", http.StatusOK, http.StatusRequestEntityTooLarge, tasks},
+		{"import batch", http.MethodPost, "/tasks/import", http.StatusOK, http.StatusRequestEntityTooLarge, tasks},
+		{"batch update", http.MethodPost, "/tasks/batch-update", http.StatusOK, http.StatusRequestEntityTooLarge, batch},
+		{"create tags", http.MethodPost, "/tasks", http.StatusOK, http.StatusUnprocessableEntity, tagged},
+		{"import tags", http.MethodPost, "/tasks/import", http.StatusOK, http.StatusUnprocessableEntity, tagged},
+		{"update tags", http.MethodPut, "/tasks/target", http.StatusCreated, http.StatusUnprocessableEntity, func(n int) string { return `{"tags":` + tagList(n) + `}` }},
+	} {
+		for n, want := range map[int]int{2: tc.ok, 3: tc.ok, 4: tc.tooLarge} {
+			w := do(t, h, tc.method, tc.target, tc.body(n))
+			if w.Code != want {
+				t.Errorf("%s of %d: status %d, want %d: %s", tc.name, n, w.Code, want, w.Body.String())
+				continue
+			}
+			warning := w.Header().Get("Warning")
+			if wantWarning := n == 3; (warning != "") != wantWarning {
+				t.Errorf("%s of %d: Warning = %q, want one: %v", tc.name, n, warning, wantWarning)
+			} else if wantWarning && !strings.Contains(warning, "above the soft limit of 2, requests above 3 will be rejected") {
+				t.Errorf("%s of %d: Warning = %q", tc.name, n, warning)
+			}
+		}
+	}
+}
+
+func tagList(n int) string {
+	tags := make([]string, n)
+	for i := range tags {
+		tags[i] = `"tag` + strconv.Itoa(i) + `"`
+	}
+	return "[" + strings.Join(tags, ",") + "]"
+}
+
+func TestCapabilities(t *testing.T) {
+	s, h := newTestServer(t, nil)
+	var caps Capabilities
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/capabilities", ""), &caps)
+	if caps.Storage != "memory" || caps.Compaction || caps.ReadOnly || caps.Maintenance || caps.StrictQuery || caps.AdminAPI || len(caps.Notifiers) != 0 || !caps.AsyncOperations {
+		t.Errorf("default capabilities = %+v", caps)
+	}
+
+	// Every advertised value must be one the server accepts.
+	for _, format := range caps.TimeFormats {
+		mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks", "", "Accept", "application/json; time-format="+format)
+		if !validTimeFormat(format) {
+			t.Errorf("time format %q is advertised but invalid", format)
+		}
+	}
+	for _, policy := range caps.EmptyFields {
+		if !validEmptyFields(policy) {
+			t.Errorf("empty_fields %q is advertised but invalid", policy)
+		}
+	}
+	for _, field := range caps.SortFields {
+		mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks?sort="+field, "")
+	}
+	for _, field := range caps.GroupBy {
+		mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks/group-count?by="+field, "")
+	}
+	for _, version := range caps.SchemaVersions {
+		mustDo(t, h, http.StatusOK, http.MethodPost, "/tasks/validate-batch", `[]`, "X-Schema-Version", version)
+	}
+	for _, codec := range caps.Codecs {
+		if got := mustDo(t, h, http.StatusOK, http.MethodGet, "/tasks", "", "Accept", codec).Header().Get("Content-Type"); got != codec {
+			t.Errorf("Accept %s answered with %s", codec, got)
+		}
+	}
+
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	s.Config.Maintenance = &MaintenanceWindow{Start: now, End: now.Add(time.Hour)}
+	s.Config.StrictQuery = true
+	s.Config.AdminToken = "secret"
+	s.Config.Notifiers = []string{"log"}
+	s.DB = compactingDB{MapDB: s.DB.(*MapDB)}
+	s.readOnly.Store(true)
+
+	decodeBody(t, mustDo(t, h, http.StatusOK, http.MethodGet, "/capabilities", ""), &caps)
+	if !caps.Compaction || !caps.ReadOnly || !caps.Maintenance || !caps.StrictQuery || !caps.AdminAPI || strings.Join(caps.Notifiers, ",") != "log" {
+		t.Errorf("configured capabilities = %+v", caps)
+	}
+	if caps.Storage == "memory" {
+		t.Error("storage still reported as memory for another backend")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+type MaintenanceWindow struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// BlockReads extends the window to reads; by default only writes are
+	// turned away.
+	BlockReads bool `json:"block_reads,omitempty"`
+}
+
+func (mw MaintenanceWindow) Contains(at time.Time) bool {
+	return !at.Before(mw.Start) && at.Before(mw.End)
+}
+
+type MaintenanceResponse struct {
+	Error  string            `json:"error"`
+	Window MaintenanceWindow `json:"window"`
+}
+
+// clock returns the current time. Tests and tools may replace s.now.
+func (s *Server) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// withMaintenance answers 503 during the configured window, with Retry-After
+// pointing at its end. /readyz stays reachable so probes keep working.
+func (s *Server) withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		window := s.Config.Maintenance
+		now := s.clock()
+
+		if window == nil || !window.Contains(now) || r.URL.Path == "/readyz" || (!window.BlockReads && !isWriteRequest(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.setRetryAfter(w, r, window.End.Sub(now))
+		writeEncoded(w, r, http.StatusServiceUnavailable, MaintenanceResponse{Error: "maintenance", Window: *window})
+	})
+}
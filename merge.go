@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (s *Server) MergeTask(w http.ResponseWriter, r *http.Request, ID string) {
+	var body struct {
+		Into string `json:"into"`
+	}
+
+	if err := requestCodec(r).Decode(r.Body, &body); err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if body.Into == "" {
+		http.Error(w, "into is required", http.StatusBadRequest)
+		return
+	}
+
+	task, err := s.DB.MergeTasks(ID, body.Into, s.Config.TagLimit.Hard)
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, ErrInvalidValue) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if errors.Is(err, ErrArchived) {
+		http.Error(w, ErrArchived.Error(), http.StatusConflict)
+		return
+	} else if errors.Is(err, ErrTooManyTags) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	checkLimit(w, "tags per task", s.Config.TagLimit, len(task.Tags), http.StatusUnprocessableEntity)
+	writeWritten(w, r, http.StatusOK, taskLocation(task.ID), s.presentTask(r, *task))
+}
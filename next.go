@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// moreUrgent orders candidates for /tasks/next: higher priority first, then
+// the soonest due date, then the oldest task, with ID as the final tie-break.
+func moreUrgent(a, b Task) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if c := compareOptionalTime(a.DueAt, b.DueAt); c != 0 {
+		return c < 0
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return strings.Compare(a.ID, b.ID) < 0
+}
+
+// nextTask picks the most urgent task assigned to assignee that can be
+// worked on. Done and blocked tasks are not actionable, and neither is a task
+// that depends on one still open. tasks must hold every active task so those
+// dependencies can be found; archived or unknown dependencies do not block.
+func nextTask(tasks []Task, assignee string) (Task, bool) {
+	statuses := make(map[string]string, len(tasks))
+	for _, task := range tasks {
+		statuses[task.ID] = task.Status
+	}
+
+	var next Task
+	found := false
+
+	for _, task := range tasks {
+		if task.Assignee != assignee || task.Status == "done" || task.Status == "blocked" {
+			continue
+		}
+		if hasOpenDependency(task, statuses) {
+			continue
+		}
+		if !found || moreUrgent(task, next) {
+			next = task
+			found = true
+		}
+	}
+
+	return next, found
+}
+
+func hasOpenDependency(task Task, statuses map[string]string) bool {
+	for _, dep := range task.DependsOn {
+		if status, ok := statuses[dep]; ok && status != "done" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleNext(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetNextTask(w, r)
+}
+
+func (s *Server) GetNextTask(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, []string{"assignee"}) {
+		return
+	}
+
+	assignee := r.URL.Query().Get("assignee")
+	if assignee == "" {
+		http.Error(w, "assignee is required", http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(TaskFilter{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.canonicalStatuses(tasks)
+
+	task, ok := nextTask(tasks, assignee)
+	if !ok {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, s.presentTask(r, task))
+}
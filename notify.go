@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type TaskEvent struct {
+	Seq  uint64                 `json:"seq"`
+	Type string                 `json:"type"`
+	At   time.Time              `json:"at"`
+	Task Task                   `json:"task"`
+	Diff map[string]FieldChange `json:"diff,omitempty"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, event TaskEvent) error
+}
+
+type NopNotifier struct{}
+
+func (NopNotifier) Notify(ctx context.Context, event TaskEvent) error { return nil }
+
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func (n LogNotifier) Notify(ctx context.Context, event TaskEvent) error {
+	logf := log.Printf
+	if n.Logger != nil {
+		logf = n.Logger.Printf
+	}
+	fields := make([]string, 0, len(event.Diff))
+	for field := range event.Diff {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	logf("task event #%d: %s %q %v\n", event.Seq, event.Type, event.Task.ID, fields)
+	return nil
+}
+
+func notifierByName(name string) (Notifier, error) {
+	switch name {
+	case "nop":
+		return NopNotifier{}, nil
+	case "log":
+		return LogNotifier{}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", name)
+	}
+}
+
+// NotifierHub queues events and hands each notifier its own queue and
+// delivery goroutine, so writers never wait on delivery and a slow sink
+// only holds up itself. At most batchSize deliveries run at once. A
+// notifier that does not answer within timeout is dropped for good; if it
+// also ignores its context, the one call it is stuck in is abandoned, so
+// the leak is bounded by one goroutine per dropped notifier. Events for a
+// full queue are dropped and logged.
+type NotifierHub struct {
+	queue   chan TaskEvent
+	sinks   []*notifierSink
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+// notifierSink is the per-notifier queue the hub fans events out to.
+type notifierSink struct {
+	notifier Notifier
+	events   chan TaskEvent
+	dropped  atomic.Bool
+}
+
+func NewNotifierHub(buffer, batchSize int, timeout time.Duration, notifiers ...Notifier) *NotifierHub {
+	sinks := make([]*notifierSink, len(notifiers))
+	for i, notifier := range notifiers {
+		sinks[i] = &notifierSink{notifier: notifier, events: make(chan TaskEvent, buffer)}
+	}
+
+	return &NotifierHub{
+		queue:   make(chan TaskEvent, buffer),
+		sinks:   sinks,
+		slots:   make(chan struct{}, batchSize),
+		timeout: timeout,
+	}
+}
+
+// Run delivers queued events until ctx is cancelled, then delivers whatever
+// is still queued before returning, so shutdown does not drop events.
+func (h *NotifierHub) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, sink := range h.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.drain(sink)
+		}()
+	}
+
+	defer func() {
+		for _, sink := range h.sinks {
+			close(sink.events)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case event := <-h.queue:
+			h.fanOut(event)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-h.queue:
+					h.fanOut(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (h *NotifierHub) fanOut(event TaskEvent) {
+	for _, sink := range h.sinks {
+		if sink.dropped.Load() {
+			continue
+		}
+		select {
+		case sink.events <- event:
+		default:
+			log.Printf("notifier %T: queue is full, dropping event #%d\n", sink.notifier, event.Seq)
+		}
+	}
+}
+
+// drain delivers the events of one sink in order until its queue is
+// closed or the notifier misses the timeout.
+func (h *NotifierHub) drain(sink *notifierSink) {
+	for event := range sink.events {
+		if h.deliver(sink.notifier, event) {
+			continue
+		}
+
+		log.Printf("notifier %T: no answer within %v for event #%d, dropping it\n", sink.notifier, h.timeout, event.Seq)
+		sink.dropped.Store(true)
+		for range sink.events {
+		}
+		return
+	}
+}
+
+// deliver sends event to one notifier and reports whether it answered in
+// time. The delivery slot is released when the timeout passes, even if the
+// notifier ignores its context and keeps the call running.
+func (h *NotifierHub) deliver(notifier Notifier, event TaskEvent) bool {
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- notifier.Notify(ctx, event)
+	}()
+
+	select {
+	case err := <-result:
+		if errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		if err != nil {
+			log.Printf("notifier %T: event #%d: %v\n", notifier, event.Seq, err)
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (h *NotifierHub) Publish(event TaskEvent) {
+	select {
+	case h.queue <- event:
+	default:
+		log.Printf("notifier queue is full, dropping event #%d\n", event.Seq)
+	}
+}
+
+type ChangeSubscriber interface {
+	OnChange(fn func(Change))
+}
+
+func (db *MapDB) OnChange(fn func(Change)) {
+	db.changes.mx.Lock()
+	defer db.changes.mx.Unlock()
+
+	db.changes.subscribers = append(db.changes.subscribers, fn)
+}
+
+func (h *NotifierHub) publishChange(change Change) {
+	h.Publish(TaskEvent{Seq: change.Seq, Type: change.Type, At: change.At, Task: change.Task, Diff: change.Diff})
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+	OperationCancelled = "cancelled"
+)
+
+// importChunkSize is how many tasks an async import writes per lock, so
+// readers get a turn and a cancellation takes effect between chunks.
+const importChunkSize = 500
+
+var ErrOperationDone = errors.New("operation has already finished")
+
+// Operation is the pollable state of a long-running request.
+type Operation struct {
+	ID         string        `json:"id"`
+	Type       string        `json:"type"`
+	Status     string        `json:"status"`
+	Total      int           `json:"total"`
+	Processed  int           `json:"processed"`
+	Result     *ImportResult `json:"result,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+func (op *Operation) finished() bool {
+	return op.FinishedAt != nil
+}
+
+// snapshot copies the operation so it can be read after store.mx is
+// released while the import keeps updating the original.
+func (op *Operation) snapshot() Operation {
+	copied := *op
+	if op.Result != nil {
+		result := *op.Result
+		copied.Result = &result
+	}
+	return copied
+}
+
+// operationStore keeps operations in memory. Finished ones are dropped once
+// they are older than ttl; running ones are kept until they finish.
+type operationStore struct {
+	mx  sync.Mutex
+	ops map[string]*Operation
+	ttl time.Duration
+}
+
+func newOperationStore(ttl time.Duration) *operationStore {
+	return &operationStore{ops: make(map[string]*Operation), ttl: ttl}
+}
+
+// pruneLocked drops expired operations. store.mx must be held.
+func (store *operationStore) pruneLocked(now time.Time) {
+	for ID, op := range store.ops {
+		if op.finished() && now.Sub(*op.FinishedAt) > store.ttl {
+			delete(store.ops, ID)
+		}
+	}
+}
+
+func (store *operationStore) create(opType string, total int, cancel context.CancelFunc) Operation {
+	var id [8]byte
+	rand.Read(id[:])
+
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	now := time.Now()
+	store.pruneLocked(now)
+
+	op := &Operation{ID: hex.EncodeToString(id[:]), Type: opType, Status: OperationPending, Total: total, CreatedAt: now, cancel: cancel}
+	store.ops[op.ID] = op
+	return op.snapshot()
+}
+
+func (store *operationStore) get(ID string) (Operation, bool) {
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	store.pruneLocked(time.Now())
+
+	op, ok := store.ops[ID]
+	if !ok {
+		return Operation{}, false
+	}
+	return op.snapshot(), true
+}
+
+// update applies fn to a live operation under the store lock.
+func (store *operationStore) update(ID string, fn func(op *Operation)) {
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	if op, ok := store.ops[ID]; ok {
+		fn(op)
+	}
+}
+
+func (store *operationStore) finish(ID, status string, err error) {
+	store.update(ID, func(op *Operation) {
+		now := time.Now()
+		op.Status = status
+		op.FinishedAt = &now
+		if err != nil {
+			op.Error = err.Error()
+		}
+	})
+}
+
+// cancel asks a pending or running operation to stop. It stops between
+// chunks, so the status turns to cancelled shortly after.
+func (store *operationStore) cancel(ID string) (Operation, error) {
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	op, ok := store.ops[ID]
+	if !ok {
+		return Operation{}, ErrNotFound
+	}
+	if op.finished() {
+		return op.snapshot(), ErrOperationDone
+	}
+	op.cancel()
+	return op.snapshot(), nil
+}
+
+func operationLocation(ID string) string {
+	return "/operations/" + ID
+}
+
+// startImport runs an import in chunks in the background. It waits for an
+// import slot like a synchronous import would, but queues instead of being
+// turned away. Chunks written before a cancellation stay written, and so do
+// those written before read-only mode or a maintenance window stops it.
+func (s *Server) startImport(tasks []Task, updateExisting bool) Operation {
+	ctx, cancel := context.WithCancel(context.Background())
+	op := s.operations.create("import", len(tasks), cancel)
+
+	s.jobs.Go("import operation", func(jobCtx context.Context) {
+		stop := context.AfterFunc(jobCtx, cancel)
+		defer stop()
+		defer cancel()
+
+		s.runImport(ctx, op.ID, tasks, updateExisting)
+	})
+
+	return op
+}
+
+func (s *Server) runImport(ctx context.Context, ID string, tasks []Task, updateExisting bool) {
+	select {
+	case s.imports <- struct{}{}:
+		defer func() { <-s.imports }()
+	case <-ctx.Done():
+		s.operations.finish(ID, OperationCancelled, nil)
+		return
+	}
+
+	s.operations.update(ID, func(op *Operation) {
+		op.Status = OperationRunning
+		op.Result = &ImportResult{}
+	})
+
+	for start := 0; start < len(tasks); start += importChunkSize {
+		if ctx.Err() != nil {
+			s.operations.finish(ID, OperationCancelled, nil)
+			return
+		}
+		if err := s.writesPaused(); err != nil {
+			s.operations.finish(ID, OperationFailed, err)
+			return
+		}
+
+		chunk := tasks[start:min(start+importChunkSize, len(tasks))]
+		result, err := s.DB.ImportTasks(chunk, updateExisting)
+		if err != nil {
+			s.operations.finish(ID, OperationFailed, fmt.Errorf("DB error: %v", err))
+			return
+		}
+
+		s.operations.update(ID, func(op *Operation) {
+			op.Processed += len(chunk)
+			op.Result.Created += result.Created
+			op.Result.Updated += result.Updated
+			op.Result.Skipped += result.Skipped
+		})
+	}
+
+	s.operations.finish(ID, OperationSucceeded, nil)
+}
+
+// writesPaused reports why background writes must stop: read-only mode or
+// a maintenance window turns away the same writes over HTTP.
+func (s *Server) writesPaused() error {
+	if s.readOnly.Load() {
+		return errors.New("stopped: the server is in read-only mode")
+	}
+	if window := s.Config.Maintenance; window != nil && window.Contains(s.clock()) {
+		return fmt.Errorf("stopped: maintenance window %q", window.Name)
+	}
+	return nil
+}
+
+func (s *Server) handleOperation(w http.ResponseWriter, r *http.Request) {
+	ID := strings.TrimPrefix(r.URL.Path, "/operations/")
+	if ID == "" || strings.Contains(ID, "/") {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.GetOperation(w, r, ID)
+	case http.MethodDelete:
+		s.CancelOperation(w, r, ID)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) GetOperation(w http.ResponseWriter, r *http.Request, ID string) {
+	op, ok := s.operations.get(ID)
+	if !ok {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, op)
+}
+
+func (s *Server) CancelOperation(w http.ResponseWriter, r *http.Request, ID string) {
+	op, err := s.operations.cancel(ID)
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, ErrOperationDone) {
+		http.Error(w, ErrOperationDone.Error(), http.StatusConflict)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusAccepted, op)
+}
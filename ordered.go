@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// topoSort orders tasks so that each one comes after the tasks it depends on,
+// using Kahn's algorithm. Ready tasks are taken in ID order to keep the
+// result stable. Dependencies on tasks outside the list are ignored. When a
+// cycle remains, one cycle is returned instead.
+func topoSort(tasks []Task) ([]Task, []string) {
+	byID := make(map[string]Task, len(tasks))
+	inDegree := make(map[string]int, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+		inDegree[task.ID] = 0
+	}
+
+	dependents := make(map[string][]string)
+	for _, task := range tasks {
+		for _, dep := range task.DependsOn {
+			if _, ok := byID[dep]; !ok {
+				continue
+			}
+			inDegree[task.ID]++
+			dependents[dep] = append(dependents[dep], task.ID)
+		}
+	}
+
+	var ready []string
+	for ID, degree := range inDegree {
+		if degree == 0 {
+			ready = append(ready, ID)
+		}
+	}
+	sort.Strings(ready)
+
+	ordered := make([]Task, 0, len(tasks))
+	for len(ready) > 0 {
+		ID := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, byID[ID])
+
+		for _, dependent := range dependents[ID] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				i, _ := slices.BinarySearch(ready, dependent)
+				ready = slices.Insert(ready, i, dependent)
+			}
+		}
+	}
+
+	if len(ordered) < len(byID) {
+		return nil, findCycle(byID, inDegree)
+	}
+	return ordered, nil
+}
+
+// findCycle walks dependencies among the tasks Kahn's algorithm could not
+// place. Every such task has an unplaced dependency, so the walk must
+// revisit a task, and the path from that task on is a cycle.
+func findCycle(byID map[string]Task, inDegree map[string]int) []string {
+	var start string
+	for ID, degree := range inDegree {
+		if degree > 0 && (start == "" || ID < start) {
+			start = ID
+		}
+	}
+
+	var path []string
+	seen := make(map[string]int)
+	for ID := start; ; {
+		if i, ok := seen[ID]; ok {
+			return append(path[i:], ID)
+		}
+		seen[ID] = len(path)
+		path = append(path, ID)
+
+		for _, dep := range byID[ID].DependsOn {
+			if inDegree[dep] > 0 {
+				ID = dep
+				break
+			}
+		}
+	}
+}
+
+func (s *Server) handleOrdered(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetOrderedTasks(w, r)
+}
+
+func (s *Server) GetOrderedTasks(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, filterParams) {
+		return
+	}
+
+	filter, err := s.parseTaskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ordered, cycle := topoSort(tasks)
+	if cycle != nil {
+		http.Error(w, fmt.Sprintf("dependency cycle: %s", strings.Join(cycle, " -> ")), http.StatusConflict)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, s.presentTasks(r, ordered))
+}
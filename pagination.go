@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+type Page struct {
+	Limit  int
+	Offset int
+	After  *Task
+}
+
+func (p Page) IsSet() bool {
+	return p.Limit > 0 || p.Offset > 0 || p.After != nil
+}
+
+// The cursor carries the ID and sort key of the last task of a page so the
+// next page can resume after it even if the list changed in between.
+func encodeCursor(task Task, field string) string {
+	data, _ := json.Marshal(task)
+
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields)
+
+	key := map[string]json.RawMessage{"id": fields["id"]}
+	if value, ok := fields[field]; ok {
+		key[field] = value
+	}
+
+	data, _ = json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (*Task, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func parsePage(r *http.Request, maxOffset int) (Page, error) {
+	var page Page
+	query := r.URL.Query()
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return page, errors.New("limit must be a non-negative integer")
+		}
+		page.Limit = limit
+	}
+
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return page, errors.New("offset must be a non-negative integer")
+		}
+		if maxOffset > 0 && offset > maxOffset {
+			return page, fmt.Errorf("offset %d exceeds the maximum of %d; use cursor pagination with ?after=<X-Next-Cursor> instead", offset, maxOffset)
+		}
+		page.Offset = offset
+	}
+
+	if v := query.Get("after"); v != "" {
+		if page.Offset > 0 {
+			return page, errors.New("offset and after cannot be combined")
+		}
+		after, err := decodeCursor(v)
+		if err != nil {
+			return page, errors.New("after is not a valid cursor")
+		}
+		page.After = after
+	}
+
+	return page, nil
+}
+
+// paginateTasks returns the requested page of tasks already sorted by spec
+// together with the cursor for the next one, which is empty on the last page.
+func paginateTasks(tasks []Task, page Page, spec SortSpec) ([]Task, string) {
+	start := page.Offset
+	if page.After != nil {
+		start = sort.Search(len(tasks), func(i int) bool {
+			return spec.Less(*page.After, tasks[i])
+		})
+	}
+	if start > len(tasks) {
+		start = len(tasks)
+	}
+
+	end := len(tasks)
+	if page.Limit > 0 && start+page.Limit < end {
+		end = start + page.Limit
+	}
+
+	next := ""
+	if end < len(tasks) && end > start {
+		next = encodeCursor(tasks[end-1], spec.Field)
+	}
+
+	return tasks[start:end], next
+}
+
+func writePageHeaders(w http.ResponseWriter, total int, next string) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+}
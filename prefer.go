@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// preferMinimal reports whether the client sent Prefer: return=minimal.
+// return=representation, or no preference, keeps the full body.
+func preferMinimal(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(preference), "return=minimal") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func taskLocation(ID string) string {
+	return "/tasks/" + url.PathEscape(ID)
+}
+
+// tasksLocation is only set when a write touched exactly one task.
+func tasksLocation(tasks []Task) string {
+	if len(tasks) != 1 {
+		return ""
+	}
+	return taskLocation(tasks[0].ID)
+}
+
+// writeWritten answers a write. With return=minimal it sends 204 and only the
+// Location of the written task, if there is a single one.
+func writeWritten(w http.ResponseWriter, r *http.Request, status int, location string, v any) {
+	if !preferMinimal(r) {
+		writeEncoded(w, r, status, v)
+		return
+	}
+
+	if location != "" {
+		w.Header().Set("Location", location)
+	}
+	w.Header().Set("Preference-Applied", "return=minimal")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+)
+
+// presentTask prepares a stored task for a response. It works on a copy so
+// the stored record is never modified by response-only rules.
+func (s *Server) presentTask(r *http.Request, task Task) Task {
+	task.Checksum = taskChecksum(task)
+	task.timeFormat = s.requestTimeFormat(r)
+	task.emptyFields = s.requestEmptyFields(r)
+	task.Status = s.canonicalStatus(task.Status)
+
+	loc := requestLocation(r)
+	task.CreatedAt = task.CreatedAt.In(loc)
+	task.UpdatedAt = task.UpdatedAt.In(loc)
+	if task.StartedAt != nil {
+		startedAt := task.StartedAt.In(loc)
+		task.StartedAt = &startedAt
+	}
+	if task.CompletedAt != nil {
+		completedAt := task.CompletedAt.In(loc)
+		task.CompletedAt = &completedAt
+	}
+	if task.ArchivedAt != nil {
+		archivedAt := task.ArchivedAt.In(loc)
+		task.ArchivedAt = &archivedAt
+	}
+	if task.DueAt != nil {
+		dueAt := task.DueAt.In(loc)
+		task.DueAt = &dueAt
+	}
+
+	if task.LockedBy != "" && task.lockOwner(s.clock()) == "" {
+		task.clearLock()
+	}
+	if task.LockedAt != nil {
+		lockedAt := task.LockedAt.In(loc)
+		task.LockedAt = &lockedAt
+	}
+	if task.LockExpiresAt != nil {
+		expiresAt := task.LockExpiresAt.In(loc)
+		task.LockExpiresAt = &expiresAt
+	}
+
+	task.RemainingMinutes = max(task.EstimateMinutes-task.SpentMinutes, 0)
+	task.LeadTimeSeconds, task.CycleTimeSeconds = flowTimes(task)
+
+	if s.Config.SortTags && len(task.Tags) > 0 {
+		tags := make([]string, len(task.Tags))
+		copy(tags, task.Tags)
+		sort.Strings(tags)
+		task.Tags = tags
+	}
+	return task
+}
+
+func (s *Server) presentTasks(r *http.Request, tasks []Task) []Task {
+	presented := make([]Task, len(tasks))
+	for i, task := range tasks {
+		presented[i] = s.presentTask(r, task)
+	}
+	return presented
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	globalParams     = []string{"tz", "fields"}
+	filterParams     = []string{"include_archived", "status", "assignee", "tag", "due_on"}
+	paginationParams = []string{"limit", "offset", "after", "sort"}
+)
+
+// checkQuery rejects query parameters outside allowed when strict_query is
+// on, so a typo like ?statuz=done fails loudly instead of matching
+// everything. It reports whether the handler may continue.
+func (s *Server) checkQuery(w http.ResponseWriter, r *http.Request, allowed ...[]string) bool {
+	if !s.Config.StrictQuery {
+		return true
+	}
+
+	var unknown []string
+	for param := range r.URL.Query() {
+		if slices.Contains(globalParams, param) {
+			continue
+		}
+		if !slices.ContainsFunc(allowed, func(params []string) bool { return slices.Contains(params, param) }) {
+			unknown = append(unknown, param)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return true
+	}
+
+	sort.Strings(unknown)
+	http.Error(w, fmt.Sprintf("unknown query parameters: %s", strings.Join(unknown, ", ")), http.StatusBadRequest)
+	return false
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// readOnlySafePaths and readOnlySafeSuffixes accept POST without writing
+// anything.
+var (
+	readOnlySafePaths    = []string{"/tasks/validate-batch"}
+	readOnlySafeSuffixes = []string{"/patch/preview"}
+)
+
+func isWriteRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	for _, path := range readOnlySafePaths {
+		if r.URL.Path == path {
+			return false
+		}
+	}
+	for _, suffix := range readOnlySafeSuffixes {
+		if strings.HasSuffix(r.URL.Path, suffix) {
+			return false
+		}
+	}
+	// Cancelling an operation stops writes rather than making them.
+	if r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/operations/") {
+		return false
+	}
+	return true
+}
+
+// withReadOnly rejects writes with 503 while read-only mode is on, so the
+// store can be migrated or backed up without new changes arriving.
+func (s *Server) withReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly.Load() && isWriteRequest(r) {
+			http.Error(w, "read-only", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// toggleReadOnlyOnSignal flips read-only mode on every SIGUSR1.
+func (s *Server) toggleReadOnlyOnSignal(jobs *Lifecycle) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	jobs.Go("read-only toggle", func(ctx context.Context) {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				readOnly := !s.readOnly.Load()
+				s.readOnly.Store(readOnly)
+				log.Printf("read-only mode: %v\n", readOnly)
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
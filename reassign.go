@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type ReassignRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status string `json:"status"`
+}
+
+func (s *Server) handleReassign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.ReassignTasks(w, r)
+}
+
+func (s *Server) ReassignTasks(w http.ResponseWriter, r *http.Request) {
+	var req ReassignRequest
+
+	if err := decodeJSON(r.Body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.From == "" || req.To == "" {
+		http.Error(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.checkAssignee(req.To); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// The status may be an alias; Match compares canonical statuses, so
+	// tasks still stored under a legacy name are reassigned too.
+	filter := TaskFilter{Assignee: req.From, Status: s.canonicalStatus(req.Status), StatusAliases: s.Config.StatusAliases}
+	count, err := s.DB.ReassignTasks(filter, req.To)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, map[string]int{"reassigned": count})
+}
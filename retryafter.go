@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RetryAfterSeconds  = "seconds"
+	RetryAfterHTTPDate = "http-date"
+)
+
+func validRetryAfterFormat(format string) bool {
+	return format == RetryAfterSeconds || format == RetryAfterHTTPDate
+}
+
+// requestRetryAfterFormat honours Prefer: retry-after=http-date (or
+// =seconds) and otherwise falls back to the configured format.
+func (s *Server) requestRetryAfterFormat(r *http.Request) string {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(preference), "=")
+			if ok && strings.EqualFold(name, "retry-after") && validRetryAfterFormat(strings.ToLower(value)) {
+				return strings.ToLower(value)
+			}
+		}
+	}
+	return s.Config.RetryAfterFormat
+}
+
+// setRetryAfter tells the client to come back after delay, rounded up to
+// whole seconds and at least one. Both forms name the same instant.
+func (s *Server) setRetryAfter(w http.ResponseWriter, r *http.Request, delay time.Duration) {
+	seconds := max(int(math.Ceil(delay.Seconds())), 1)
+
+	if s.requestRetryAfterFormat(r) == RetryAfterHTTPDate {
+		at := s.clock().Add(time.Duration(seconds) * time.Second)
+		w.Header().Set("Retry-After", at.UTC().Format(http.TimeFormat))
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
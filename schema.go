@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CurrentSchemaVersion is the payload shape the handlers decode into.
+const CurrentSchemaVersion = "2"
+
+var ErrUnknownSchema = errors.New("unknown schema version")
+
+// schemaMigrations bring a task or update object of an older payload
+// version forward to the current one, in place.
+var schemaMigrations = map[string]func(obj map[string]interface{}){
+	// v1 had a single tag string where v2 has a tags array.
+	"1": func(obj map[string]interface{}) {
+		tag, ok := obj["tag"]
+		if !ok {
+			return
+		}
+		delete(obj, "tag")
+		if _, hasTags := obj["tags"]; hasTags {
+			return
+		}
+		if tag, ok := tag.(string); ok && strings.TrimSpace(tag) != "" {
+			obj["tags"] = []interface{}{tag}
+		} else {
+			obj["tags"] = []interface{}{}
+		}
+	},
+}
+
+// schemaMigration returns the migration for the X-Schema-Version of r, or
+// nil when the body is already in the current shape.
+func schemaMigration(r *http.Request) (func(obj map[string]interface{}), error) {
+	version := strings.TrimSpace(r.Header.Get("X-Schema-Version"))
+	if version == "" || version == CurrentSchemaVersion {
+		return nil, nil
+	}
+
+	migrate, ok := schemaMigrations[version]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSchema, version)
+	}
+	return migrate, nil
+}
+
+// migrateValue applies migrate to an object or to every object in an
+// array.
+func migrateValue(v interface{}, migrate func(obj map[string]interface{})) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		migrate(v)
+	case []interface{}:
+		for _, elem := range v {
+			if obj, ok := elem.(map[string]interface{}); ok {
+				migrate(obj)
+			}
+		}
+	}
+}
+
+// decodeMigrated decodes the next value from decode into v, migrating it
+// on the way when migrate is set.
+func decodeMigrated(decode func(v interface{}) error, v interface{}, migrate func(obj map[string]interface{})) error {
+	if migrate == nil {
+		return decode(v)
+	}
+
+	var raw interface{}
+	if err := decode(&raw); err != nil {
+		return err
+	}
+	migrateValue(raw, migrate)
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// decodeVersioned decodes a write body honouring X-Schema-Version. Unknown
+// versions fail with ErrUnknownSchema.
+func decodeVersioned(r *http.Request, v interface{}) error {
+	migrate, err := schemaMigration(r)
+	if err != nil {
+		return err
+	}
+
+	codec := requestCodec(r)
+	return decodeMigrated(func(v interface{}) error { return codec.Decode(r.Body, v) }, v, migrate)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const defaultSimilarityThreshold = 0.5
+
+type SimilarTask struct {
+	Task
+	Score float64 `json:"score"`
+}
+
+func titleTokens(title string) map[string]struct{} {
+	tokens := make(map[string]struct{})
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		tokens[word] = struct{}{}
+	}
+	return tokens
+}
+
+// titleSimilarity returns the Jaccard index of the word sets of two titles, from 0 to 1.
+func titleSimilarity(a, b string) float64 {
+	tokensA, tokensB := titleTokens(a), titleTokens(b)
+	if len(tokensA) == 0 && len(tokensB) == 0 {
+		return 0
+	}
+
+	common := 0
+	for token := range tokensA {
+		if _, ok := tokensB[token]; ok {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(tokensA)+len(tokensB)-common)
+}
+
+func findSimilarTasks(target Task, tasks []Task, threshold float64) []SimilarTask {
+	similar := []SimilarTask{}
+
+	for _, task := range tasks {
+		if task.ID == target.ID {
+			continue
+		}
+		if score := titleSimilarity(target.Title, task.Title); score >= threshold {
+			similar = append(similar, SimilarTask{Task: task, Score: score})
+		}
+	}
+
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].Score > similar[j].Score
+	})
+
+	return similar
+}
+
+func (s *Server) GetSimilarTasks(w http.ResponseWriter, r *http.Request, ID string) {
+	if !s.checkQuery(w, r, []string{"threshold"}) {
+		return
+	}
+
+	threshold := defaultSimilarityThreshold
+
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(parsed >= 0 && parsed <= 1) {
+			http.Error(w, "threshold must be a number between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		threshold = parsed
+	}
+
+	target, err := s.DB.GetTask(ID)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(TaskFilter{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeEncoded(w, r, http.StatusOK, findSimilarTasks(s.presentTask(r, *target), s.presentTasks(r, tasks), threshold))
+}
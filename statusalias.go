@@ -0,0 +1,22 @@
+package main
+
+// resolveStatus maps a legacy stored status to the one clients should see,
+// per the status_aliases config. Other statuses pass through.
+func resolveStatus(aliases map[string]string, status string) string {
+	if canonical, ok := aliases[status]; ok {
+		return canonical
+	}
+	return status
+}
+
+func (s *Server) canonicalStatus(status string) string {
+	return resolveStatus(s.Config.StatusAliases, status)
+}
+
+// canonicalStatuses rewrites fetched tasks to their canonical statuses, so
+// aggregations agree with the statuses presentTask shows.
+func (s *Server) canonicalStatuses(tasks []Task) {
+	for i := range tasks {
+		tasks[i].Status = s.canonicalStatus(tasks[i].Status)
+	}
+}
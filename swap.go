@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type SwapRequest struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+func (s *Server) handleSwap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.SwapTasks(w, r)
+}
+
+// SwapTasks exchanges the positions of two tasks for manual board
+// reordering and returns both of them.
+func (s *Server) SwapTasks(w http.ResponseWriter, r *http.Request) {
+	var body SwapRequest
+	if err := requestCodec(r).Decode(r.Body, &body); err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if body.A == "" || body.B == "" {
+		http.Error(w, "a and b are required", http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.DB.SwapTaskPositions(body.A, body.B)
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, ErrInvalidValue) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if errors.Is(err, ErrArchived) || errors.Is(err, ErrOtherColumn) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeWritten(w, r, http.StatusOK, "", s.presentTasks(r, tasks))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	DuplicateTagsDedupe = "dedupe"
+	DuplicateTagsReject = "reject"
+)
+
+var ErrDuplicateTag = errors.New("duplicate tag")
+
+// sameTag compares tags the way duplicates are detected: surrounding spaces
+// and case do not matter, so "Backend" and "backend " are the same tag.
+func sameTag(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// dedupeTags keeps the first spelling of every tag, trimmed, in order.
+func dedupeTags(tags []string) ([]string, []string) {
+	unique := make([]string, 0, len(tags))
+	var duplicates []string
+
+outer:
+	for _, tag := range tags {
+		for _, seen := range unique {
+			if sameTag(seen, tag) {
+				duplicates = append(duplicates, tag)
+				continue outer
+			}
+		}
+		unique = append(unique, strings.TrimSpace(tag))
+	}
+
+	return unique, duplicates
+}
+
+// checkUpdateTags applies the duplicate_tags policy to the tags of an update
+// body, rewriting them in place when duplicates are dropped.
+func (s *Server) checkUpdateTags(data map[string]interface{}) error {
+	rawTags, ok := data["tags"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	tags := make([]string, 0, len(rawTags))
+	for _, rawTag := range rawTags {
+		if tag, ok := rawTag.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+
+	unique, duplicates := dedupeTags(tags)
+	if len(duplicates) > 0 && s.Config.DuplicateTags == DuplicateTagsReject {
+		return fmt.Errorf("%w: %q", ErrDuplicateTag, duplicates)
+	}
+
+	deduped := make([]interface{}, len(unique))
+	for i, tag := range unique {
+		deduped[i] = tag
+	}
+	data["tags"] = deduped
+
+	return nil
+}
+
+// retag removes and then adds tags, comparing them with sameTag, and reports
+// whether anything changed.
+func retag(tags, add, remove []string) ([]string, bool) {
+	result := make([]string, 0, len(tags)+len(add))
+	changed := false
+
+	for _, tag := range tags {
+		if slices.ContainsFunc(remove, func(r string) bool { return sameTag(r, tag) }) {
+			changed = true
+			continue
+		}
+		result = append(result, tag)
+	}
+	for _, tag := range add {
+		if !slices.ContainsFunc(result, func(t string) bool { return sameTag(t, tag) }) {
+			result = append(result, strings.TrimSpace(tag))
+			changed = true
+		}
+	}
+
+	return result, changed
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type SortSpec struct {
+	Field string
+	Desc  bool
+}
+
+var sortableFields = map[string]func(a, b Task) int{
+	"id":         func(a, b Task) int { return strings.Compare(a.ID, b.ID) },
+	"title":      func(a, b Task) int { return strings.Compare(a.Title, b.Title) },
+	"status":     func(a, b Task) int { return strings.Compare(a.Status, b.Status) },
+	"assignee":   func(a, b Task) int { return strings.Compare(a.Assignee, b.Assignee) },
+	"created_at": func(a, b Task) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"updated_at": func(a, b Task) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+	"due_at":     func(a, b Task) int { return compareOptionalTime(a.DueAt, b.DueAt) },
+	"priority":   func(a, b Task) int { return cmp.Compare(a.Priority, b.Priority) },
+	"position":   func(a, b Task) int { return cmp.Compare(a.Position, b.Position) },
+}
+
+// compareOptionalTime orders unset times after every set one.
+func compareOptionalTime(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	default:
+		return a.Compare(*b)
+	}
+}
+
+// parseSort accepts "field", "-field" or "field asc|desc".
+func parseSort(value string) (SortSpec, error) {
+	var spec SortSpec
+
+	parts := strings.Fields(value)
+	switch len(parts) {
+	case 1:
+		spec.Field, spec.Desc = strings.CutPrefix(parts[0], "-")
+	case 2:
+		spec.Field = parts[0]
+		switch strings.ToLower(parts[1]) {
+		case "asc":
+		case "desc":
+			spec.Desc = true
+		default:
+			return spec, fmt.Errorf("sort direction must be asc or desc, got %q", parts[1])
+		}
+	default:
+		return spec, fmt.Errorf("invalid sort %q", value)
+	}
+
+	if _, ok := sortableFields[spec.Field]; !ok {
+		return spec, fmt.Errorf("cannot sort by %q", spec.Field)
+	}
+
+	return spec, nil
+}
+
+// Less orders by the sort field and breaks ties by ID, so the order is total
+// and can be resumed from a cursor.
+func (spec SortSpec) Less(a, b Task) bool {
+	c := sortableFields[spec.Field](a, b)
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if spec.Desc {
+		return c > 0
+	}
+	return c < 0
+}
+
+func sortTasks(tasks []Task, spec SortSpec) {
+	sort.Slice(tasks, func(i, j int) bool {
+		return spec.Less(tasks[i], tasks[j])
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	TimeFormatRFC3339 = "rfc3339"
+	TimeFormatUnix    = "unix"
+	TimeFormatUnixMS  = "unix_ms"
+)
+
+// timeFormats lists every supported time_format, in the order capabilities
+// reports them.
+var timeFormats = []string{TimeFormatRFC3339, TimeFormatUnix, TimeFormatUnixMS}
+
+func validTimeFormat(format string) bool {
+	return slices.Contains(timeFormats, format)
+}
+
+// requestTimeFormat honours a time-format parameter on an application/json
+// Accept entry, e.g. "application/json; time-format=unix_ms", and otherwise
+// falls back to the configured format.
+func (s *Server) requestTimeFormat(r *http.Request) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || mediaType != "application/json" {
+			continue
+		}
+		if format := params["time-format"]; validTimeFormat(format) {
+			return format
+		}
+	}
+	return s.Config.TimeFormat
+}
+
+func formatEpoch(t time.Time, format string) int64 {
+	if format == TimeFormatUnixMS {
+		return t.UnixMilli()
+	}
+	return t.Unix()
+}
+
+func formatOptionalEpoch(t *time.Time, format string) *int64 {
+	if t == nil {
+		return nil
+	}
+	epoch := formatEpoch(*t, format)
+	return &epoch
+}
+
+func (t Task) MarshalJSON() ([]byte, error) {
+	data, err := t.marshalTimes()
+	if err != nil || t.emptyFields == "" || t.emptyFields == EmptyFieldsDefault {
+		return data, err
+	}
+	return applyEmptyFields(data, t.emptyFields)
+}
+
+// marshalTimes encodes the task with its timestamps in t.timeFormat.
+func (t Task) marshalTimes() ([]byte, error) {
+	type plain Task
+
+	if t.timeFormat != TimeFormatUnix && t.timeFormat != TimeFormatUnixMS {
+		return json.Marshal(plain(t))
+	}
+
+	return json.Marshal(struct {
+		plain
+		CreatedAt   int64  `json:"created_at"`
+		UpdatedAt   int64  `json:"updated_at"`
+		StartedAt   *int64 `json:"started_at,omitempty"`
+		CompletedAt *int64 `json:"completed_at,omitempty"`
+		ArchivedAt  *int64 `json:"archived_at,omitempty"`
+		DueAt       *int64 `json:"due_at,omitempty"`
+
+		LockedAt      *int64 `json:"locked_at,omitempty"`
+		LockExpiresAt *int64 `json:"lock_expires_at,omitempty"`
+	}{
+		plain:       plain(t),
+		CreatedAt:   formatEpoch(t.CreatedAt, t.timeFormat),
+		UpdatedAt:   formatEpoch(t.UpdatedAt, t.timeFormat),
+		StartedAt:   formatOptionalEpoch(t.StartedAt, t.timeFormat),
+		CompletedAt: formatOptionalEpoch(t.CompletedAt, t.timeFormat),
+		ArchivedAt:  formatOptionalEpoch(t.ArchivedAt, t.timeFormat),
+		DueAt:       formatOptionalEpoch(t.DueAt, t.timeFormat),
+
+		LockedAt:      formatOptionalEpoch(t.LockedAt, t.timeFormat),
+		LockExpiresAt: formatOptionalEpoch(t.LockExpiresAt, t.timeFormat),
+	})
+}
+
+// SimilarTask embeds Task, so it needs its own marshaler to keep the score
+// next to the promoted Task one.
+func (t SimilarTask) MarshalJSON() ([]byte, error) {
+	data, err := t.Task.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data[:len(data)-1], fmt.Sprintf(`,"score":%g}`, t.Score)...), nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type locationKey struct{}
+
+var locationCache sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locationCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(name, loc)
+
+	return loc, nil
+}
+
+// withTimezone resolves ?tz= (or the configured default) once per request so
+// filters and responses agree on which local day a timestamp falls on.
+func (s *Server) withTimezone(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("tz")
+		if name == "" {
+			name = s.Config.Timezone
+		}
+
+		loc, err := loadLocation(name)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid time zone %q", name), http.StatusBadRequest)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), locationKey{}, loc)))
+	})
+}
+
+func requestLocation(r *http.Request) *time.Location {
+	if loc, ok := r.Context().Value(locationKey{}).(*time.Location); ok {
+		return loc
+	}
+	return time.UTC
+}
+
+// localDay returns the [start, end) instants of the calendar day in loc.
+func localDay(date string, loc *time.Location) (time.Time, time.Time, error) {
+	day, err := time.ParseInLocation(time.DateOnly, date, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return day, day.AddDate(0, 0, 1), nil
+}
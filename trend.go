@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTrendBuckets keeps a tiny bucket over a long window from producing
+// an unbounded response.
+const maxTrendBuckets = 1000
+
+type TrendBucket struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Created   int       `json:"created"`
+	Completed int       `json:"completed"`
+	Archived  int       `json:"archived"`
+}
+
+type TrendResponse struct {
+	Window  string        `json:"window"`
+	Bucket  string        `json:"bucket"`
+	Buckets []TrendBucket `json:"buckets"`
+}
+
+// trendSpan is a window or bucket length. Days are kept apart from the
+// clock duration so day buckets follow local midnight across DST changes.
+type trendSpan struct {
+	days     int
+	duration time.Duration
+}
+
+func parseTrendSpan(key, value string) (trendSpan, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return trendSpan{}, fmt.Errorf("%s must be a positive number of days such as 7d, or a duration such as 12h", key)
+		}
+		return trendSpan{days: n}, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return trendSpan{}, fmt.Errorf("%s must be a positive number of days such as 7d, or a duration such as 12h", key)
+	}
+	return trendSpan{duration: d}, nil
+}
+
+// trendBuckets lays out the buckets covering window and ending with the one
+// that contains now. Day buckets start at local midnight in loc.
+func trendBuckets(window, bucket trendSpan, now time.Time, loc *time.Location) ([]TrendBucket, error) {
+	var count int
+	switch {
+	case bucket.days > 0 && window.days > 0:
+		if window.days%bucket.days != 0 {
+			return nil, fmt.Errorf("window must be a whole number of buckets")
+		}
+		count = window.days / bucket.days
+	case bucket.days > 0:
+		return nil, fmt.Errorf("window must be a whole number of buckets")
+	default:
+		length := window.duration
+		if window.days > 0 {
+			length = time.Duration(window.days) * 24 * time.Hour
+		}
+		if length%bucket.duration != 0 {
+			return nil, fmt.Errorf("window must be a whole number of buckets")
+		}
+		count = int(length / bucket.duration)
+	}
+	if count > maxTrendBuckets {
+		return nil, fmt.Errorf("window holds %d buckets, at most %d are allowed", count, maxTrendBuckets)
+	}
+
+	now = now.In(loc)
+	buckets := make([]TrendBucket, count)
+	if bucket.days > 0 {
+		last := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		for i := range buckets {
+			start := last.AddDate(0, 0, -(count-1-i)*bucket.days)
+			buckets[i] = TrendBucket{Start: start, End: start.AddDate(0, 0, bucket.days)}
+		}
+		return buckets, nil
+	}
+
+	last := now.Truncate(bucket.duration)
+	for i := range buckets {
+		start := last.Add(-time.Duration(count-1-i) * bucket.duration)
+		buckets[i] = TrendBucket{Start: start, End: start.Add(bucket.duration)}
+	}
+	return buckets, nil
+}
+
+// bucketIndex returns the bucket holding at, or -1 outside the window.
+func bucketIndex(buckets []TrendBucket, at *time.Time) int {
+	if at == nil || len(buckets) == 0 || at.Before(buckets[0].Start) || !at.Before(buckets[len(buckets)-1].End) {
+		return -1
+	}
+
+	lo, hi := 0, len(buckets)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if at.Before(buckets[mid].Start) {
+			hi = mid - 1
+		} else {
+			lo = mid
+		}
+	}
+	return lo
+}
+
+// countTrend adds every task to the buckets its created, completed and
+// archived timestamps fall into, in one pass over the tasks.
+func countTrend(tasks []Task, buckets []TrendBucket) {
+	for _, task := range tasks {
+		if i := bucketIndex(buckets, &task.CreatedAt); i >= 0 {
+			buckets[i].Created++
+		}
+		if i := bucketIndex(buckets, task.CompletedAt); i >= 0 {
+			buckets[i].Completed++
+		}
+		if i := bucketIndex(buckets, task.ArchivedAt); i >= 0 {
+			buckets[i].Archived++
+		}
+	}
+}
+
+func (s *Server) handleTrend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetTrend(w, r)
+}
+
+// GetTrend reports created, completed and archived counts per bucket over a
+// rolling window, e.g. ?window=7d&bucket=1d. Both default to those values.
+func (s *Server) GetTrend(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, []string{"window", "bucket"}) {
+		return
+	}
+
+	query := r.URL.Query()
+	windowValue, bucketValue := query.Get("window"), query.Get("bucket")
+	if windowValue == "" {
+		windowValue = "7d"
+	}
+	if bucketValue == "" {
+		bucketValue = "1d"
+	}
+
+	window, err := parseTrendSpan("window", windowValue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bucket, err := parseTrendSpan("bucket", bucketValue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := trendBuckets(window, bucket, s.clock(), requestLocation(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(TaskFilter{IncludeArchived: true})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	countTrend(tasks, buckets)
+
+	writeEncoded(w, r, http.StatusOK, TrendResponse{Window: windowValue, Bucket: bucketValue, Buckets: buckets})
+}
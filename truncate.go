@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+)
+
+type countingWriter struct {
+	n int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += len(p)
+	return len(p), nil
+}
+
+// fitResponse returns how many of the encoded items fit in maxBytes, counting
+// one byte of separator per item on top of the array brackets. At least one
+// item is always kept so a client paging through can make progress.
+func fitResponse(codec Codec, items []Task, maxBytes int) int {
+	size := 2
+	for i, item := range items {
+		var cw countingWriter
+		codec.Encode(&cw, item)
+
+		size += cw.n + 1
+		if size > maxBytes && i > 0 {
+			return i
+		}
+	}
+	return len(items)
+}
+
+// truncateResponse cuts a list response down to Config.MaxResponseBytes. The
+// cut is signalled with X-Truncated and an X-Next-Cursor to resume after the
+// last task sent. It returns the tasks to write.
+func (s *Server) truncateResponse(w http.ResponseWriter, r *http.Request, tasks, presented []Task, spec SortSpec) []Task {
+	if s.Config.MaxResponseBytes <= 0 {
+		return presented
+	}
+
+	n := fitResponse(responseCodec(r), presented, s.Config.MaxResponseBytes)
+	if n == len(presented) {
+		return presented
+	}
+
+	w.Header().Set("X-Truncated", "true")
+	w.Header().Set("X-Next-Cursor", encodeCursor(tasks[n-1], spec.Field))
+	return presented[:n]
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNestedValue = errors.New("value is nested too deeply")
+
+// valueDepth is 0 for scalars and one more than the deepest element for
+// arrays and objects, so a list of tags has depth 1.
+func valueDepth(v interface{}) int {
+	depth := 0
+	switch v := v.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			depth = max(depth, valueDepth(elem))
+		}
+		return depth + 1
+	case map[string]interface{}:
+		for _, elem := range v {
+			depth = max(depth, valueDepth(elem))
+		}
+		return depth + 1
+	}
+	return depth
+}
+
+// checkUpdateDepth rejects update values nested deeper than maxDepth. No
+// updatable field takes more than a flat list, so anything deeper is noise
+// the generic map would otherwise carry around.
+func checkUpdateDepth(data map[string]interface{}, maxDepth int) error {
+	for key, value := range data {
+		if depth := valueDepth(value); depth > maxDepth {
+			return fmt.Errorf("%s: %w (depth %d, at most %d)", key, ErrNestedValue, depth, maxDepth)
+		}
+	}
+	return nil
+}
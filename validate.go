@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type ValidationResult struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"`
+}
+
+func validateTask(task Task) []string {
+	errs := []string{}
+
+	if task.ID == "" {
+		errs = append(errs, "id is required")
+	} else if strings.Contains(task.ID, "/") {
+		errs = append(errs, "id must not contain '/'")
+	}
+
+	if strings.TrimSpace(task.Title) == "" {
+		errs = append(errs, "title is required")
+	}
+	if task.Status == "archived" {
+		errs = append(errs, "status archived is set by archiving, not on create")
+	}
+
+	if task.EstimateMinutes < 0 {
+		errs = append(errs, "estimate_minutes must not be negative")
+	}
+	if task.SpentMinutes < 0 {
+		errs = append(errs, "spent_minutes must not be negative")
+	}
+	if task.Priority < 0 {
+		errs = append(errs, "priority must not be negative")
+	}
+	if task.Position < 0 {
+		errs = append(errs, "position must not be negative")
+	}
+	if task.ID != "" && slices.Contains(task.DependsOn, task.ID) {
+		errs = append(errs, "a task must not depend on itself")
+	}
+
+	return errs
+}
+
+// applyDefaultPriorities gives tasks whose body named no priority the
+// configured default for the status they start in. An explicit priority,
+// 0 included, always wins. prioritySet stays false, so an import row that
+// updates a stored task keeps the stored priority.
+func (s *Server) applyDefaultPriorities(tasks []Task) {
+	for i := range tasks {
+		if tasks[i].prioritySet {
+			continue
+		}
+		status := tasks[i].Status
+		if status == "" {
+			status = InitialStatus
+		}
+		if priority, ok := s.Config.DefaultPriorities[s.canonicalStatus(status)]; ok {
+			tasks[i].Priority = priority
+		}
+	}
+}
+
+// taskBody is the decoded shape of a task in a request body. Priority is
+// a pointer so that an omitted priority is told apart from 0.
+type taskBody struct {
+	plainTask
+	Priority *int `json:"priority"`
+}
+
+type plainTask Task
+
+func (body taskBody) task() Task {
+	task := Task(body.plainTask)
+	if body.Priority != nil {
+		task.Priority = *body.Priority
+		task.prioritySet = true
+	}
+	return task
+}
+
+func (t *Task) UnmarshalJSON(data []byte) error {
+	body := taskBody{plainTask: plainTask(*t)}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+	*t = body.task()
+	return nil
+}
+
+func (t *Task) DecodeMsgpack(dec *msgpack.Decoder) error {
+	body := taskBody{plainTask: plainTask(*t)}
+	if err := dec.Decode(&body); err != nil {
+		return err
+	}
+	*t = body.task()
+	return nil
+}
+
+// validateUpdate runs the checks an update body must pass before it is
+// applied. It may rewrite the tags in data according to the duplicate_tags
+// policy.
+func (s *Server) validateUpdate(data map[string]interface{}) error {
+	if err := checkUpdateDepth(data, s.Config.MaxUpdateDepth); err != nil {
+		return err
+	}
+	if err := s.checkAssignee(updateAssignee(data)); err != nil {
+		return err
+	}
+	if err := s.checkUpdateTags(data); err != nil {
+		return err
+	}
+	return s.Config.TagLimit.check("tags per task", updateTagCount(data))
+}
+
+func (s *Server) handleValidateBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.ValidateBatch(w, r)
+}
+
+func (s *Server) ValidateBatch(w http.ResponseWriter, r *http.Request) {
+	migrate, err := schemaMigration(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	dec := newBodyDecoder(r.Body)
+
+	tok, err := dec.Token()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("JSON error: %v", err), http.StatusBadRequest)
+		return
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		http.Error(w, "JSON error: expected an array of tasks", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	// A syntax error ends the stream, but the array is still closed with a
+	// final result describing the error, so the response stays valid JSON.
+	fmt.Fprint(w, "[")
+	i := 0
+	writeResult := func(result ValidationResult) {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		enc.Encode(result)
+		if flusher != nil && i%100 == 99 {
+			flusher.Flush()
+		}
+		i++
+	}
+
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			log.Printf("validate-batch: item %d: %v\n", i, err)
+			writeResult(ValidationResult{Errors: []string{fmt.Sprintf("JSON error: %v", err)}})
+			fmt.Fprint(w, "]")
+			return
+		}
+
+		// A well-formed item of the wrong shape is an invalid task, not a
+		// broken stream.
+		var task Task
+		unmarshal := func(v interface{}) error { return json.Unmarshal(raw, v) }
+		if err := decodeMigrated(unmarshal, &task, migrate); err != nil {
+			writeResult(ValidationResult{Errors: []string{fmt.Sprintf("JSON error: %v", err)}})
+			continue
+		}
+
+		errs := validateTask(task)
+		writeResult(ValidationResult{Valid: len(errs) == 0, Errors: errs})
+	}
+	if _, err := dec.Token(); err != nil {
+		writeResult(ValidationResult{Errors: []string{fmt.Sprintf("JSON error: %v", err)}})
+	}
+	fmt.Fprint(w, "]")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// warmUp runs the startup tasks and then waits out the configured delay.
+// /readyz reports "starting" until it returns, so load balancers hold back
+// traffic from a half-initialised server.
+func (s *Server) warmUp(jobs *Lifecycle, tasks ...func() error) {
+	s.warmingUp.Store(true)
+
+	jobs.Go("warm-up", func(ctx context.Context) {
+		started := time.Now()
+		for _, task := range tasks {
+			if err := task(); err != nil {
+				log.Printf("startup task failed: %v\n", err)
+			}
+		}
+
+		select {
+		case <-time.After(time.Duration(s.Config.StartupDelayMS)*time.Millisecond - time.Since(started)):
+		case <-ctx.Done():
+			return
+		}
+
+		s.warmingUp.Store(false)
+		log.Printf("warm-up finished in %v\n", time.Since(started).Round(time.Millisecond))
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type AssigneeWorkload struct {
+	Assignee   string `json:"assignee"`
+	Open       int    `json:"open"`
+	InProgress int    `json:"in_progress"`
+	Overdue    int    `json:"overdue"`
+
+	EstimateMinutes int `json:"estimate_minutes"`
+}
+
+// computeWorkload aggregates not-done tasks per assignee in one pass.
+// Unassigned tasks are not part of anyone's workload and are skipped.
+func computeWorkload(tasks []Task, now time.Time) []AssigneeWorkload {
+	byAssignee := make(map[string]*AssigneeWorkload)
+
+	for _, task := range tasks {
+		if task.Assignee == "" || task.Status == "done" {
+			continue
+		}
+
+		workload, ok := byAssignee[task.Assignee]
+		if !ok {
+			workload = &AssigneeWorkload{Assignee: task.Assignee}
+			byAssignee[task.Assignee] = workload
+		}
+
+		workload.Open++
+		if task.Status == "in_progress" {
+			workload.InProgress++
+		}
+		if task.DueAt != nil && task.DueAt.Before(now) {
+			workload.Overdue++
+		}
+		workload.EstimateMinutes += task.EstimateMinutes
+	}
+
+	result := make([]AssigneeWorkload, 0, len(byAssignee))
+	for _, workload := range byAssignee {
+		result = append(result, *workload)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Assignee < result[j].Assignee
+	})
+
+	return result
+}
+
+func (s *Server) handleWorkload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.GetWorkload(w, r)
+}
+
+func (s *Server) GetWorkload(w http.ResponseWriter, r *http.Request) {
+	if !s.checkQuery(w, r, []string{"tag"}) {
+		return
+	}
+
+	tasks, err := s.DB.GetTasks(TaskFilter{Tag: r.URL.Query().Get("tag")})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DB error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.canonicalStatuses(tasks)
+
+	writeEncoded(w, r, http.StatusOK, computeWorkload(tasks, s.clock()))
+}